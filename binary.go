@@ -0,0 +1,255 @@
+package sortedset
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	binaryMagic   = "SSET"
+	binaryVersion = 1
+)
+
+var (
+	// ErrInvalidData is returned by UnmarshalBinary when the data is truncated or malformed
+	ErrInvalidData = errors.New("sortedset: invalid binary data")
+	// ErrUnsupportedVersion is returned by UnmarshalBinary when the data was written by an unknown format version
+	ErrUnsupportedVersion = errors.New("sortedset: unsupported binary format version")
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*SortedSet)(nil)
+	_ encoding.BinaryUnmarshaler = (*SortedSet)(nil)
+)
+
+// ValueCodec encodes and decodes the Value of nodes for MarshalBinary and UnmarshalBinary.
+// Nil values are handled by the set and never given to the codec.
+type ValueCodec interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// GobCodec is the default ValueCodec, it encodes values with encoding/gob.
+// Like any interface value sent through gob, the concrete types of the values
+// other than the basic types must be registered with gob.Register.
+type GobCodec struct{}
+
+// Marshal encode value with encoding/gob
+func (GobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decode a value encoded by Marshal
+func (GobCodec) Unmarshal(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// WithValueCodec make MarshalBinary and UnmarshalBinary encode values with codec instead of GobCodec
+func WithValueCodec(codec ValueCodec) Option {
+	return func(set *SortedSet) {
+		set.codec = codec
+	}
+}
+
+func (set *SortedSet) valueCodec() ValueCodec {
+	if set.codec == nil {
+		return GobCodec{}
+	}
+	return set.codec
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+//
+// The format starts with a magic string and a version byte, followed by the
+// number of nodes and every node in rank order as a length-prefixed key, the
+// score and a length-prefixed value encoded by the ValueCodec. TTLs and
+// versions are not saved.
+//
+// Time complexity of this method is : O(N)
+func (set *SortedSet) MarshalBinary() ([]byte, error) {
+	set.evictExpired()
+	codec := set.valueCodec()
+
+	buf := make([]byte, 0, 16+set.length*32)
+	buf = append(buf, binaryMagic...)
+	buf = append(buf, binaryVersion)
+	buf = binary.AppendUvarint(buf, uint64(set.length))
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		var err error
+		if buf, err = appendBinaryNode(buf, x.key, x.score, x.Value, codec); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+//
+// The content of the set is replaced by the nodes in data, its options are
+// kept. As nodes are stored in rank order, the skiplist is rebuilt without
+// searching. It can be called on the zero value of SortedSet.
+//
+// Time complexity of this method is : O(N)
+func (set *SortedSet) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binaryMagic)+1 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return ErrInvalidData
+	}
+	if data[len(binaryMagic)] != binaryVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, data[len(binaryMagic)])
+	}
+	r := binaryReader{data: data[len(binaryMagic)+1:]}
+	codec := set.valueCodec()
+
+	count := r.uvarint()
+	if r.err != nil || count > uint64(len(r.data)) {
+		return ErrInvalidData
+	}
+	nodes := make([]*Node, 0, count)
+	for i := uint64(0); i < count; i++ {
+		key, score, value, err := r.node(codec)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, &Node{key: key, score: score, Value: value})
+	}
+	if r.err != nil || len(r.data) != 0 {
+		return ErrInvalidData
+	}
+
+	// build aside so the set is left untouched if the nodes are not ordered
+	loaded := *set
+	loaded.reset()
+	loaded.listeners = nil
+	loaded.indexes = nil
+	b := newBuilder(&loaded)
+	for _, x := range nodes {
+		if err := b.append(x); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidData, err)
+		}
+	}
+	b.finish()
+	loaded.listeners = set.listeners
+	loaded.indexes = set.indexes
+	set.replaceWith(&loaded)
+	return nil
+}
+
+/* Append a node as a length-prefixed key, the score and a flag telling whether
+ * a length-prefixed value encoded by codec follows. */
+func appendBinaryNode(buf []byte, key string, score float64, value interface{}, codec ValueCodec) ([]byte, error) {
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(score))
+	if value == nil {
+		return append(buf, 0), nil
+	}
+	encoded, err := codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("sortedset: marshal value of %q: %w", key, err)
+	}
+	buf = append(buf, 1)
+	buf = binary.AppendUvarint(buf, uint64(len(encoded)))
+	return append(buf, encoded...), nil
+}
+
+/* Remove every node, keeping the options. It also initializes the zero value. */
+func (set *SortedSet) reset() {
+	set.header = createNode(SkiplistMaxLevel, math.Inf(-1), "", nil)
+	set.tail = nil
+	set.length = 0
+	set.digest = 0
+	set.level = 1
+	set.dict = make(map[string]*Node)
+	set.expires = nil
+	set.pending = nil
+	set.version++
+	if set.r == nil {
+		set.r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if set.now == nil {
+		set.now = time.Now
+	}
+}
+
+// binaryReader decodes the binary format, remembering the first error
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidData
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.err = ErrInvalidData
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *binaryReader) uint64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+/* Read a node written by appendBinaryNode. */
+func (r *binaryReader) node(codec ValueCodec) (key string, score float64, value interface{}, err error) {
+	key = string(r.bytes(r.uvarint()))
+	score = math.Float64frombits(r.uint64())
+	switch r.byte() {
+	case 0: // nil value
+	case 1:
+		encoded := r.bytes(r.uvarint())
+		if r.err != nil {
+			return "", 0, nil, r.err
+		}
+		if value, err = codec.Unmarshal(encoded); err != nil {
+			return "", 0, nil, fmt.Errorf("sortedset: unmarshal value of %q: %w", key, err)
+		}
+	default:
+		r.err = ErrInvalidData
+	}
+	return key, score, value, r.err
+}
+
+func (r *binaryReader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
@@ -0,0 +1,122 @@
+package sortedset
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// checkRanks verifies spans and backward links by looking up every node by rank and key
+func checkRanks(t *testing.T, sortedset *SortedSet) {
+	t.Helper()
+	if err := sortedset.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	nodes := sortedset.GetByRankRange(1, -1, false)
+	if len(nodes) != sortedset.GetCount() {
+		t.Fatalf("GetByRankRange() returns %d nodes, expected %d", len(nodes), sortedset.GetCount())
+	}
+	for i, node := range nodes {
+		if rank := sortedset.FindRank(node.Key()); rank != i+1 {
+			t.Errorf("FindRank(%q) returns %d, expected %d", node.Key(), rank, i+1)
+		}
+		if byRank := sortedset.GetByRank(i+1, false); byRank != node {
+			t.Errorf("GetByRank(%d) does not return %q", i+1, node.Key())
+		}
+		if i > 0 && node.Previous() != nodes[i-1] {
+			t.Errorf("previous node of %q is not %q", node.Key(), nodes[i-1].Key())
+		}
+	}
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Marshal(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("not a string")
+	}
+	return []byte(s), nil
+}
+
+func (stringCodec) Unmarshal(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func TestMarshalBinary(t *testing.T) {
+	sortedset := New()
+	for i := 0; i < 1000; i++ {
+		sortedset.AddOrUpdate(fmt.Sprint(i), float64(i%37), i)
+	}
+	sortedset.AddOrUpdate("nil", 5, nil)
+	sortedset.AddOrUpdate("string", 5, "value")
+
+	data, err := sortedset.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded SortedSet
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	checkRanks(t, &loaded)
+
+	expected := sortedset.GetByRankRange(1, -1, false)
+	actual := loaded.GetByRankRange(1, -1, false)
+	if len(actual) != len(expected) {
+		t.Fatalf("loaded set has %d nodes, expected %d", len(actual), len(expected))
+	}
+	for i := range expected {
+		if actual[i].Key() != expected[i].Key() || actual[i].Score() != expected[i].Score() || actual[i].Value != expected[i].Value {
+			t.Errorf("node %d is %q %v %v, expected %q %v %v", i,
+				actual[i].Key(), actual[i].Score(), actual[i].Value,
+				expected[i].Key(), expected[i].Score(), expected[i].Value)
+		}
+	}
+
+	// the loaded set keeps working like any other set
+	loaded.AddOrUpdate("new", 10.5, nil)
+	loaded.Remove("0")
+	loaded.GetByRankRange(100, 200, true)
+	checkRanks(t, &loaded)
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	sortedset := New(WithValueCodec(stringCodec{}))
+	sortedset.AddOrUpdate("a", 1, "Alice")
+	sortedset.AddOrUpdate("b", 2, "Bob")
+	data, err := sortedset.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New(WithValueCodec(stringCodec{}))
+	loaded.AddOrUpdate("untouched", 1, "")
+	for i := 0; i < len(data); i++ {
+		if err := loaded.UnmarshalBinary(data[:i]); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("UnmarshalBinary() of data truncated at %d returns %v", i, err)
+		}
+	}
+	if loaded.GetByKey("untouched") == nil {
+		t.Error("a failed UnmarshalBinary() should leave the set untouched")
+	}
+
+	unsupported := append([]byte{}, data...)
+	unsupported[len(binaryMagic)] = 42
+	if err := loaded.UnmarshalBinary(unsupported); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("UnmarshalBinary() of an unknown version returns %v", err)
+	}
+
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if node := loaded.GetByKey("b"); node == nil || node.Value != "Bob" {
+		t.Error("values should be decoded with the codec of the set")
+	}
+
+	sortedset.AddOrUpdate("c", 3, 42)
+	if _, err := sortedset.MarshalBinary(); err == nil {
+		t.Error("MarshalBinary() should return the error of the codec")
+	}
+}
@@ -0,0 +1,224 @@
+package sortedset
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrNotSorted is returned when entries expected in rank order are not ordered by score and key
+	ErrNotSorted = errors.New("sortedset: entries are not sorted by score and key")
+	// ErrDuplicateKey is returned when entries expected to have unique keys contain a key twice
+	ErrDuplicateKey = errors.New("sortedset: duplicate key")
+	// ErrNotEmpty is returned by BulkLoad when the set already contains elements
+	ErrNotEmpty = errors.New("sortedset: the set is not empty")
+)
+
+// Entry is an element given to the methods adding elements in bulk
+type Entry struct {
+	Key   string
+	Score float64
+	Value interface{}
+}
+
+// NewFromSorted Create a new SortedSet from entries already in rank order,
+// that is ordered by score, then by key for the same score. Entries are read
+// by calling next until it returns false.
+//
+// The skiplist is built bottom-up without searching, which is much faster
+// than calling AddOrUpdate for every entry. ErrNotSorted or ErrDuplicateKey is
+// returned if the entries are not strictly ordered.
+//
+// Time complexity of this method is : O(N)
+func NewFromSorted(next func() (Entry, bool), options ...Option) (*SortedSet, error) {
+	set := New(options...)
+	if err := set.bulkLoad(next); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// BulkLoad Fill an empty set with entries already in rank order, see NewFromSorted.
+// ErrNotEmpty is returned if the set contains elements. On error, the set is left empty
+//
+// Time complexity of this method is : O(N)
+func (set *SortedSet) BulkLoad(entries []Entry) error {
+	set.evictExpired()
+	if set.length > 0 {
+		return ErrNotEmpty
+	}
+	i := 0
+	return set.bulkLoad(func() (Entry, bool) {
+		if i == len(entries) {
+			return Entry{}, false
+		}
+		i++
+		return entries[i-1], true
+	})
+}
+
+func (set *SortedSet) bulkLoad(next func() (Entry, bool)) error {
+	b := newBuilder(set)
+	for {
+		entry, ok := next()
+		if !ok {
+			break
+		}
+		x := createNode(set.randomLevel(), entry.Score, entry.Key, entry.Value)
+		if err := b.append(x); err != nil {
+			b.finish()
+			set.reset()
+			return err
+		}
+		set.beforeChange(x.key, nil)
+	}
+	b.finish()
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		set.afterChange(x.key, x)
+	}
+	set.evictOverflow()
+	return nil
+}
+
+// AddOrUpdateBatch Add or update many elements at once, like calling
+// AddOrUpdate for every entry, and return the number of added and updated
+// elements. If a key appears more than once, its last entry wins.
+//
+// The entries are sorted first and inserted in ascending order, every search
+// starting from the position of the previous insertion instead of the header,
+// which is much faster than independent calls for large batches. Sets created
+// with WithMaxSize fall back to calling AddOrUpdate for every entry.
+//
+// Time complexity of this method is : O(M*log(M) + M*log(N/M)) for M entries on average
+func (set *SortedSet) AddOrUpdateBatch(entries []Entry) (added int, updated int) {
+	set.evictExpired()
+
+	// keep the last entry of every key
+	last := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		last[entry.Key] = i
+	}
+	batch := make([]Entry, 0, len(last))
+	for i, entry := range entries {
+		if last[entry.Key] == i {
+			batch = append(batch, entry)
+		}
+	}
+
+	if set.maxSize > 0 {
+		// evictions interleave with insertions, keep the exact behavior of AddOrUpdate
+		for _, entry := range batch {
+			found := set.dict[entry.Key] != nil
+			if node, _ := set.put(entry.Key, entry.Score, entry.Value); node == nil {
+				continue
+			}
+			if found {
+				updated++
+			} else {
+				added++
+			}
+		}
+		return added, updated
+	}
+
+	// update values in place and delete the nodes whose score changes before
+	// inserting anything, as the finger is invalidated by deletions
+	inserts := batch[:0]
+	replaced := make(map[string]*Node)
+	for _, entry := range batch {
+		found := set.dict[entry.Key]
+		if found == nil {
+			added++
+		} else {
+			updated++
+			if sameScore(found.score, entry.Score) {
+				set.put(entry.Key, entry.Score, entry.Value)
+				continue
+			}
+			set.beforeChange(entry.Key, found)
+			set.delete(found.score, found.key)
+			replaced[entry.Key] = found
+		}
+		inserts = append(inserts, entry)
+	}
+
+	sort.Slice(inserts, func(i, j int) bool {
+		if sameScore(inserts[i].Score, inserts[j].Score) {
+			return inserts[i].Key < inserts[j].Key
+		}
+		return inserts[i].Score < inserts[j].Score
+	})
+
+	var f finger
+	for _, entry := range inserts {
+		found := replaced[entry.Key]
+		if found == nil {
+			set.beforeChange(entry.Key, nil)
+		}
+		set.insertReplacing(&f, found, entry.Key, entry.Score, entry.Value)
+	}
+	return added, updated
+}
+
+// builder appends nodes given in rank order at the tail of an empty set,
+// linking every level directly instead of searching from the header.
+type builder struct {
+	set  *SortedSet
+	last [SkiplistMaxLevel]*Node // last node linked at each level
+	rank [SkiplistMaxLevel]int   // rank of last[i]
+}
+
+func newBuilder(set *SortedSet) *builder {
+	b := &builder{set: set}
+	for i := range b.last {
+		b.last[i] = set.header
+	}
+	return b
+}
+
+/* Append a detached node, it must be ordered after the previous one. */
+func (b *builder) append(x *Node) error {
+	set := b.set
+	if prev := b.last[0]; prev != set.header {
+		if sameScore(x.score, prev.score) && x.key <= prev.key ||
+			!sameScore(x.score, prev.score) && x.score < prev.score {
+			return fmt.Errorf("%w: %q (%v) after %q (%v)", ErrNotSorted, x.key, x.score, prev.key, prev.score)
+		}
+		x.backward = prev
+	}
+	if set.dict[x.key] != nil {
+		return fmt.Errorf("%w %q", ErrDuplicateKey, x.key)
+	}
+	if x.level == nil {
+		x.level = make([]Level, set.randomLevel())
+	}
+	if x.version == 0 {
+		set.version++
+		x.version = set.version
+	}
+
+	set.length++
+	set.digest += elementHash(x.key, x.score)
+	if len(x.level) > set.level {
+		set.level = len(x.level)
+	}
+	for i := range x.level {
+		b.last[i].level[i].forward = x
+		b.last[i].level[i].span = set.length - b.rank[i]
+		b.last[i] = x
+		b.rank[i] = set.length
+	}
+	set.tail = x
+	set.dict[x.key] = x
+	return nil
+}
+
+/* Terminate every level, spans of the last nodes reach the end of the list. */
+func (b *builder) finish() {
+	set := b.set
+	for i := 0; i < set.level; i++ {
+		b.last[i].level[i].forward = nil
+		b.last[i].level[i].span = set.length - b.rank[i]
+	}
+}
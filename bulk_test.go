@@ -0,0 +1,146 @@
+package sortedset
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestNewFromSorted(t *testing.T) {
+	i := 0
+	sortedset, err := NewFromSorted(func() (Entry, bool) {
+		if i == 1000 {
+			return Entry{}, false
+		}
+		i++
+		return Entry{Key: fmt.Sprintf("%04d", i), Score: float64(i / 10), Value: i}, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sortedset.GetCount() != 1000 {
+		t.Fatalf("GetCount() returns %d, expected 1000", sortedset.GetCount())
+	}
+	checkRanks(t, sortedset)
+	if node := sortedset.GetByRank(500, false); node.Key() != "0500" || node.Value != 500 {
+		t.Errorf("GetByRank(500) returns %q %v", node.Key(), node.Value)
+	}
+
+	sortedset.AddOrUpdate("0000", 50, nil)
+	sortedset.Remove("0999")
+	sortedset.GetByRankRange(10, 20, true)
+	checkRanks(t, sortedset)
+}
+
+func TestBulkLoad(t *testing.T) {
+	sortedset := New()
+	if err := sortedset.BulkLoad([]Entry{{Key: "b", Score: 1}, {Key: "a", Score: 1}}); !errors.Is(err, ErrNotSorted) {
+		t.Errorf("BulkLoad() of unordered keys returns %v", err)
+	}
+	if err := sortedset.BulkLoad([]Entry{{Key: "a", Score: 2}, {Key: "b", Score: 1}}); !errors.Is(err, ErrNotSorted) {
+		t.Errorf("BulkLoad() of unordered scores returns %v", err)
+	}
+	if err := sortedset.BulkLoad([]Entry{{Key: "a", Score: 1}, {Key: "b", Score: 2}, {Key: "a", Score: 3}}); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("BulkLoad() of duplicate keys returns %v", err)
+	}
+	if sortedset.GetCount() != 0 || sortedset.PeekMin() != nil || sortedset.PeekMax() != nil {
+		t.Error("a failed BulkLoad() should leave the set empty")
+	}
+
+	if err := sortedset.BulkLoad([]Entry{{Key: "a", Score: 1}, {Key: "b", Score: 1}, {Key: "c", Score: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, sortedset.GetByRankRange(-1, 1, false), []string{"c", "b", "a"})
+	checkRanks(t, sortedset)
+
+	if err := sortedset.BulkLoad([]Entry{{Key: "d", Score: 3}}); err != ErrNotEmpty {
+		t.Errorf("BulkLoad() on a non-empty set returns %v", err)
+	}
+}
+
+func TestAddOrUpdateBatch(t *testing.T) {
+	sortedset := New()
+	expected := New()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprint(r.Intn(300))
+		sortedset.AddOrUpdate(key, float64(r.Intn(50)), i)
+		expected.AddOrUpdate(key, sortedset.GetByKey(key).Score(), i)
+	}
+
+	for round := 0; round < 5; round++ {
+		var entries []Entry
+		for i := 0; i < 200; i++ {
+			entries = append(entries, Entry{Key: fmt.Sprint(r.Intn(600)), Score: float64(r.Intn(50)), Value: i})
+		}
+
+		var expectedAdded, expectedUpdated int
+		seen := make(map[string]bool)
+		for _, entry := range entries {
+			if !seen[entry.Key] {
+				seen[entry.Key] = true
+				if expected.GetByKey(entry.Key) == nil {
+					expectedAdded++
+				} else {
+					expectedUpdated++
+				}
+			}
+			expected.AddOrUpdate(entry.Key, entry.Score, entry.Value)
+		}
+
+		added, updated := sortedset.AddOrUpdateBatch(entries)
+		if added != expectedAdded || updated != expectedUpdated {
+			t.Errorf("AddOrUpdateBatch() returns %d, %d, expected %d, %d", added, updated, expectedAdded, expectedUpdated)
+		}
+		checkRanks(t, sortedset)
+
+		actualNodes := sortedset.GetByRankRange(1, -1, false)
+		expectedNodes := expected.GetByRankRange(1, -1, false)
+		if len(actualNodes) != len(expectedNodes) {
+			t.Fatalf("set has %d nodes, expected %d", len(actualNodes), len(expectedNodes))
+		}
+		for i := range expectedNodes {
+			if actualNodes[i].Key() != expectedNodes[i].Key() || actualNodes[i].Value != expectedNodes[i].Value {
+				t.Errorf("node %d is %q %v, expected %q %v", i,
+					actualNodes[i].Key(), actualNodes[i].Value, expectedNodes[i].Key(), expectedNodes[i].Value)
+			}
+		}
+	}
+}
+
+func TestAddOrUpdateBatchMaxSize(t *testing.T) {
+	sortedset := New(WithMaxSize(2, EvictMin))
+	sortedset.AddOrUpdate("a", 10, nil)
+
+	added, updated := sortedset.AddOrUpdateBatch([]Entry{{Key: "a", Score: 20}, {Key: "b", Score: 5}, {Key: "c", Score: 30}, {Key: "d", Score: 1}})
+	if added != 2 || updated != 1 {
+		t.Errorf("AddOrUpdateBatch() returns %d, %d, expected 2, 1", added, updated)
+	}
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"a", "c"})
+}
+
+func BenchmarkNewFromSorted(b *testing.B) {
+	i := 0
+	b.ResetTimer()
+	NewFromSorted(func() (Entry, bool) {
+		if i == b.N {
+			return Entry{}, false
+		}
+		i++
+		return Entry{Key: fmt.Sprint(i), Score: float64(i)}, true
+	})
+}
+
+func BenchmarkAddOrUpdateBatch(b *testing.B) {
+	list := New()
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	entries := make([]Entry, 0, b.N)
+	for i := 0; i < b.N; i++ {
+		entries = append(entries, Entry{Key: fmt.Sprint(i), Score: float64(r.Intn(b.N))})
+	}
+
+	b.ResetTimer()
+	list.AddOrUpdateBatch(entries)
+}
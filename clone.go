@@ -0,0 +1,101 @@
+package sortedset
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Clone Create a copy of the set, with the same elements, TTLs, options and
+// secondary indexes. The skiplist is copied level by level, keeping the
+// levels and spans of every node, which is much faster than inserting the
+// elements one by one.
+//
+// If copyValue is not nil, the Value of every element is replaced by
+// copyValue(value) in the copy, otherwise both sets share the same values.
+// The copied values are expected to belong to the same groups of the
+// indexes as the original ones.
+// Listeners and the transaction in progress, if any, are not copied.
+//
+// Time complexity of this method is : O(N)
+func (set *SortedSet) Clone(copyValue func(value interface{}) interface{}) *SortedSet {
+	set.evictExpired()
+	clone := &SortedSet{
+		header:      createNode(SkiplistMaxLevel, math.Inf(-1), "", nil),
+		level:       set.level,
+		length:      set.length,
+		version:     set.version,
+		digest:      set.digest,
+		dict:        make(map[string]*Node, len(set.dict)),
+		r:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:         set.now,
+		maxSize:     set.maxSize,
+		evictPolicy: set.evictPolicy,
+		onEvict:     set.onEvict,
+		codec:       set.codec,
+	}
+
+	/* update[i] is the last copied node having the level i */
+	var update [SkiplistMaxLevel]*Node
+	for i := range update {
+		update[i] = clone.header
+		clone.header.level[i].span = set.header.level[i].span
+	}
+	var prev *Node
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		node := &Node{
+			key:      x.key,
+			Value:    x.Value,
+			score:    x.score,
+			version:  x.version,
+			expireAt: x.expireAt,
+			backward: prev,
+			level:    make([]Level, len(x.level)),
+		}
+		if copyValue != nil {
+			node.Value = copyValue(x.Value)
+		}
+		for i := range x.level {
+			update[i].level[i].forward = node
+			node.level[i].span = x.level[i].span
+			update[i] = node
+		}
+		clone.dict[x.key] = node
+		prev = node
+	}
+	clone.tail = prev
+
+	if set.expires != nil {
+		clone.expires = set.expires.clone()
+	}
+	if set.indexes != nil {
+		clone.indexes = make(map[string]*index, len(set.indexes))
+		for name, idx := range set.indexes {
+			clone.indexes[name] = idx.clone(clone)
+		}
+	}
+	return clone
+}
+
+// Equal Check whether the set has the same elements as other, with the same
+// scores and in the same order. Values are compared with valueEq if it is not
+// nil, otherwise they are ignored. Options and TTLs are not compared.
+//
+// Time complexity of this method is : O(N)
+func (set *SortedSet) Equal(other *SortedSet, valueEq func(a, b interface{}) bool) bool {
+	set.evictExpired()
+	other.evictExpired()
+	if set.length != other.length {
+		return false
+	}
+	x, y := set.header.level[0].forward, other.header.level[0].forward
+	for ; x != nil && y != nil; x, y = x.level[0].forward, y.level[0].forward {
+		if x.key != y.key || x.score != y.score {
+			return false
+		}
+		if valueEq != nil && !valueEq(x.Value, y.Value) {
+			return false
+		}
+	}
+	return x == nil && y == nil
+}
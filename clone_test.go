@@ -0,0 +1,95 @@
+package sortedset
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/* Levels of the skiplist by key, to compare the structure of two sets. */
+func skiplistByKey(set *SortedSet) map[string]string {
+	levels := make(map[string]string)
+	for node, snapshot := range snapshotSkiplist(set) {
+		levels[node.key] = fmt.Sprint(snapshot)
+	}
+	return levels
+}
+
+func TestClone(t *testing.T) {
+	set := New(WithMaxSize(1000, EvictMin))
+	for i := 0; i < 500; i++ {
+		set.AddOrUpdate(fmt.Sprint("key", i), float64(rand.Intn(100)), []int{i})
+	}
+	set.Expire("key1", time.Hour)
+
+	clone := set.Clone(nil)
+	if !reflect.DeepEqual(skiplistByKey(clone), skiplistByKey(set)) {
+		t.Error("the skiplist of the clone has not the same structure")
+	}
+	if !clone.Equal(set, reflect.DeepEqual) || !set.Equal(clone, nil) {
+		t.Error("the clone is not equal to the set")
+	}
+	checkRanks(t, clone)
+	if clone.PeekMax().Previous() != clone.GetByRank(-2, false) {
+		t.Error("backward links of the clone are wrong")
+	}
+	if _, ok := clone.TTL("key1"); !ok {
+		t.Error("the clone lost the TTL of key1")
+	}
+	if clone.GetByKey("key2").Value.([]int)[0] != 2 || &clone.GetByKey("key2").Value.([]int)[0] != &set.GetByKey("key2").Value.([]int)[0] {
+		t.Error("values should be shared without copyValue")
+	}
+
+	// the sets are independent
+	clone.Remove("key3")
+	clone.AddOrUpdate("new", 50, nil)
+	clone.Persist("key1")
+	if set.GetByKey("key3") == nil || set.GetByKey("new") != nil || set.GetCount() != 500 {
+		t.Error("changing the clone changed the set")
+	}
+	if _, ok := set.TTL("key1"); !ok {
+		t.Error("persisting key1 in the clone persisted it in the set")
+	}
+	if clone.Equal(set, nil) {
+		t.Error("sets with different elements are equal")
+	}
+	checkRanks(t, clone)
+	checkRanks(t, set)
+
+	deep := set.Clone(func(value interface{}) interface{} {
+		return append([]int(nil), value.([]int)...)
+	})
+	deep.GetByKey("key2").Value.([]int)[0] = -1
+	if set.GetByKey("key2").Value.([]int)[0] != 2 {
+		t.Error("values should be copied with copyValue")
+	}
+	if deep.Equal(set, reflect.DeepEqual) || !deep.Equal(set, nil) {
+		t.Error("Equal() should only compare values with valueEq")
+	}
+
+	if !New().Clone(nil).Equal(New(), nil) {
+		t.Error("clone of an empty set is not empty")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a, b := New(), New()
+	a.AddOrUpdate("x", 1, "v")
+	b.AddOrUpdate("x", 1, "v")
+	a.AddOrUpdate("y", 2, nil)
+	b.AddOrUpdate("z", 2, nil)
+	if a.Equal(b, nil) {
+		t.Error("sets with different keys are equal")
+	}
+	b.Remove("z")
+	b.AddOrUpdate("y", 3, nil)
+	if a.Equal(b, nil) {
+		t.Error("sets with different scores are equal")
+	}
+	b.AddOrUpdate("y", 2, nil)
+	if !a.Equal(b, func(a, b interface{}) bool { return a == b }) {
+		t.Error("sets with the same elements are not equal")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+/* sortedset stats [--key name] <file> */
+func stats(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	key := flags.String("key", "", "name of the set in an RDB file")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	set, format, err := open(flags.Arg(0), *key)
+	if err != nil {
+		return err
+	}
+
+	count := set.GetCount()
+	fmt.Fprintf(stdout, "format:   %s\n", format)
+	fmt.Fprintf(stdout, "members:  %d\n", count)
+	if count == 0 {
+		return nil
+	}
+
+	sum, distinct := 0.0, 0
+	for x, prev := set.PeekMin(), (*sortedset.Node)(nil); x != nil; prev, x = x, x.Next() {
+		sum += x.Score()
+		if prev == nil || x.Score() != prev.Score() {
+			distinct++
+		}
+	}
+	min, max := set.PeekMin(), set.PeekMax()
+	fmt.Fprintf(stdout, "min:      %s (%s)\n", sortedset.FormatScore(min.Score()), min.Key())
+	fmt.Fprintf(stdout, "max:      %s (%s)\n", sortedset.FormatScore(max.Score()), max.Key())
+	fmt.Fprintf(stdout, "mean:     %s\n", sortedset.FormatScore(sum/float64(count)))
+	fmt.Fprintf(stdout, "median:   %s\n", sortedset.FormatScore(set.GetByRank((count+1)/2, false).Score()))
+	fmt.Fprintf(stdout, "distinct: %d scores\n", distinct)
+	return nil
+}
+
+/* sortedset rank [--key name] <file> <member> */
+func rankCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("rank", flag.ContinueOnError)
+	key := flags.String("key", "", "name of the set in an RDB file")
+	if err := parse(flags, args, 2); err != nil {
+		return err
+	}
+	set, _, err := open(flags.Arg(0), *key)
+	if err != nil {
+		return err
+	}
+
+	member := flags.Arg(1)
+	node := set.GetByKey(member)
+	if node == nil {
+		return fmt.Errorf("member %q not found", member)
+	}
+	r := set.FindRank(member)
+	fmt.Fprintf(stdout, "member:   %s\n", member)
+	fmt.Fprintf(stdout, "score:    %s\n", sortedset.FormatScore(node.Score()))
+	fmt.Fprintf(stdout, "rank:     %d of %d, lowest score first\n", r, set.GetCount())
+	fmt.Fprintf(stdout, "rev rank: %d of %d, highest score first\n", set.GetCount()-r+1, set.GetCount())
+	return nil
+}
+
+/* sortedset range [--key name] [--rank 1:100 | --score 10:20] [--rev] <file> */
+func rangeCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("range", flag.ContinueOnError)
+	key := flags.String("key", "", "name of the set in an RDB file")
+	rankRange := flags.String("rank", "", "1-based rank range start:end, negative ranks counting from the highest score")
+	scoreRange := flags.String("score", "", "score range min:max, a bound prefixed by ( being excluded")
+	rev := flags.Bool("rev", false, "print from the highest score")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	if (*rankRange == "") == (*scoreRange == "") {
+		return usageError("exactly one of --rank and --score is required")
+	}
+	set, _, err := open(flags.Arg(0), *key)
+	if err != nil {
+		return err
+	}
+
+	var nodes []*sortedset.Node
+	if *rankRange != "" {
+		start, end, err := parseRankRange(*rankRange)
+		if err != nil {
+			return err
+		}
+		if *rev {
+			start, end = end, start
+		}
+		nodes = set.GetByRankRange(start, end, false)
+	} else {
+		min, max, options, err := parseScoreRange(*scoreRange)
+		if err != nil {
+			return err
+		}
+		if *rev {
+			min, max = max, min
+			options.ExcludeStart, options.ExcludeEnd = options.ExcludeEnd, options.ExcludeStart
+		}
+		nodes = set.GetByScoreRange(min, max, options)
+	}
+
+	for _, node := range nodes {
+		fmt.Fprintf(stdout, "%d\t%s\t%s\n", set.FindRank(node.Key()), node.Key(), sortedset.FormatScore(node.Score()))
+	}
+	return nil
+}
+
+func parseRankRange(s string) (int, int, error) {
+	start, end, ok := strings.Cut(s, ":")
+	first, err1 := strconv.Atoi(start)
+	last, err2 := strconv.Atoi(end)
+	if !ok || err1 != nil || err2 != nil || first == 0 || last == 0 {
+		return 0, 0, usageError(fmt.Sprintf("invalid rank range %q, expected start:end like 1:100", s))
+	}
+	return first, last, nil
+}
+
+func parseScoreRange(s string) (float64, float64, *sortedset.GetByScoreRangeOptions, error) {
+	options := &sortedset.GetByScoreRangeOptions{}
+	min, max, ok := strings.Cut(s, ":")
+	if options.ExcludeStart = strings.HasPrefix(min, "("); options.ExcludeStart {
+		min = min[1:]
+	}
+	if options.ExcludeEnd = strings.HasPrefix(max, "("); options.ExcludeEnd {
+		max = max[1:]
+	}
+	first, err1 := strconv.ParseFloat(min, 64)
+	last, err2 := strconv.ParseFloat(max, 64)
+	if !ok || err1 != nil || err2 != nil || first > last {
+		return 0, 0, nil, usageError(fmt.Sprintf("invalid score range %q, expected min:max like 10:20", s))
+	}
+	return first, last, options, nil
+}
+
+/* sortedset export [--key name] [--format csv|json] [-o output] <file> */
+func export(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	key := flags.String("key", "", "name of the set in an RDB file")
+	format := flags.String("format", "csv", "output format, csv or json")
+	output := flags.String("o", "", "output file instead of the standard output")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "json" {
+		return usageError(fmt.Sprintf("unknown format %q, expected csv or json", *format))
+	}
+	set, _, err := open(flags.Arg(0), *key)
+	if err != nil {
+		return err
+	}
+
+	write := writeCSV
+	if *format == "json" {
+		write = func(w io.Writer, set *sortedset.SortedSet) error {
+			return set.WriteJSON(w)
+		}
+	}
+	if *output == "" {
+		return write(stdout, set)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = write(file, set)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeCSV(w io.Writer, set *sortedset.SortedSet) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "key", "score", "value"})
+	rank := 0
+	for x := set.PeekMin(); x != nil; x = x.Next() {
+		rank++
+		value := ""
+		if x.Value != nil {
+			value = fmt.Sprint(x.Value)
+		}
+		cw.Write([]string{strconv.Itoa(rank), x.Key(), sortedset.FormatScore(x.Score()), value})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+/* sortedset verify [--key name] <file>
+ * Loading verifies the checksums of the file, then the structure of the set
+ * is checked by SortedSet.Verify. */
+func verify(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	key := flags.String("key", "", "name of the set in an RDB file")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	set, format, err := open(flags.Arg(0), *key)
+	if err != nil {
+		return err
+	}
+	if err := set.Verify(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "ok: %s file, %d members\n", format, set.GetCount())
+	return nil
+}
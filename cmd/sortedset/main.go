@@ -0,0 +1,193 @@
+// Command sortedset inspects and queries sorted set dumps offline.
+//
+// It loads a snapshot written by SaveSnapshot, a file written by
+// MarshalBinary or WriteJSON, or a Redis RDB file, the format being detected
+// from its content:
+//
+//	sortedset stats [--key name] <file>
+//	sortedset rank [--key name] <file> <member>
+//	sortedset range [--key name] [--rank 1:100 | --score 10:20] [--rev] <file>
+//	sortedset export [--key name] [--format csv|json] [-o output] <file>
+//	sortedset verify [--key name] <file>
+//	sortedset repl <file>
+//
+// The key selects a sorted set of an RDB file holding several of them. The
+// repl subcommand reads Redis-like commands such as ZRANGE or ZSCORE from the
+// standard input, the set of a non-RDB file being available under the key "set".
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/axieinfinity/sortedset"
+	"github.com/axieinfinity/sortedset/rdb"
+)
+
+const defaultKey = "set"
+
+const usage = `usage: sortedset <command> [flags] <file> [args]
+
+commands:
+  stats   print the number of members and score statistics
+  rank    print the rank and score of a member
+  range   print members by rank or score range
+  export  write the members as CSV or JSON
+  verify  check the file and the structure of the set
+  repl    run Redis-like Z* commands interactively
+
+Run 'sortedset <command> -h' for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run execute the command line args and return the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	commands := map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+		"stats":  stats,
+		"rank":   rankCommand,
+		"range":  rangeCommand,
+		"export": export,
+		"verify": verify,
+		"repl":   repl,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "sortedset: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	if err := command(args[1:], stdin, stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "sortedset %s: %v\n", args[0], err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// usageError is returned for invalid command lines, exiting with code 2
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+/* Parse the flags of a command, which must be followed by nargs arguments. */
+func parse(flags *flag.FlagSet, args []string, nargs int) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError(err.Error())
+	}
+	if flags.NArg() != nargs {
+		return usageError(fmt.Sprintf("expected %d arguments, got %d", nargs, flags.NArg()))
+	}
+	return nil
+}
+
+// load read the sorted sets of a file, keyed by name. A file which is not an
+// RDB file holds a single set, named "set".
+func load(path string) (sets map[string]*sortedset.SortedSet, format string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	options := []sortedset.Option{sortedset.WithValueCodec(displayCodec{})}
+	set := sortedset.New(options...)
+	switch trimmed := bytes.TrimLeft(data, " \t\r\n"); {
+	case bytes.HasPrefix(data, []byte("SSNP")):
+		format = "snapshot"
+		set, err = sortedset.LoadSnapshot(path, options...)
+	case bytes.HasPrefix(data, []byte("SSET")):
+		format = "binary"
+		err = set.UnmarshalBinary(data)
+	case bytes.HasPrefix(data, []byte("REDIS")):
+		sets = make(map[string]*sortedset.SortedSet)
+		err = rdb.Read(bytes.NewReader(data), func(db int, key string, set *sortedset.SortedSet) error {
+			sets[key] = set
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		return sets, "rdb", nil
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		format = "json"
+		err = set.UnmarshalJSON(data)
+	default:
+		return nil, "", fmt.Errorf("%s: unknown file format", path)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return map[string]*sortedset.SortedSet{defaultKey: set}, format, nil
+}
+
+// open load the file at path and return the set named key, which may be
+// omitted when the file holds a single set
+func open(path, key string) (*sortedset.SortedSet, string, error) {
+	sets, format, err := load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(sets) == 0 {
+		return nil, "", errors.New("the file holds no sorted set")
+	}
+	if key == "" && len(sets) == 1 {
+		for _, set := range sets {
+			return set, format, nil
+		}
+	}
+	if set := sets[key]; set != nil {
+		return set, format, nil
+	}
+
+	keys := make([]string, 0, len(sets))
+	for k := range sets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if key == "" {
+		return nil, "", usageError(fmt.Sprintf("the file holds several sets, select one with --key: %s", strings.Join(keys, ", ")))
+	}
+	return nil, "", fmt.Errorf("no set %q in the file, found: %s", key, strings.Join(keys, ", "))
+}
+
+// displayCodec decodes the values written by the default GobCodec, keeping
+// the raw bytes of the values it cannot decode, like the ones of types
+// registered with gob by the application
+type displayCodec struct{}
+
+func (displayCodec) Marshal(value interface{}) ([]byte, error) {
+	if raw, ok := value.([]byte); ok {
+		return raw, nil
+	}
+	return sortedset.GobCodec{}.Marshal(value)
+}
+
+func (displayCodec) Unmarshal(data []byte) (interface{}, error) {
+	if value, err := (sortedset.GobCodec{}).Unmarshal(data); err == nil {
+		return value, nil
+	}
+	return data, nil
+}
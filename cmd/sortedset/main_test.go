@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/axieinfinity/sortedset"
+	"github.com/axieinfinity/sortedset/rdb"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr strings.Builder
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+/* Write a snapshot of players p01 to p10 scored 10 to 100. */
+func writeSnapshot(t *testing.T) string {
+	t.Helper()
+	set := sortedset.New()
+	for i := 1; i <= 10; i++ {
+		set.AddOrUpdate(fmt.Sprintf("p%02d", i), float64(i*10), fmt.Sprint("player ", i))
+	}
+	path := filepath.Join(t.TempDir(), "board.snapshot")
+	if err := set.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStatsAndRank(t *testing.T) {
+	path := writeSnapshot(t)
+
+	code, out, _ := runCommand(t, "", "stats", path)
+	for _, expected := range []string{"format:   snapshot", "members:  10", "min:      10 (p01)", "max:      100 (p10)", "mean:     55"} {
+		if code != 0 || !strings.Contains(out, expected) {
+			t.Errorf("stats = %d %q, expected %q", code, out, expected)
+		}
+	}
+
+	code, out, _ = runCommand(t, "", "rank", path, "p03")
+	if code != 0 || !strings.Contains(out, "rank:     3 of 10") || !strings.Contains(out, "rev rank: 8 of 10") {
+		t.Errorf("rank = %d %q", code, out)
+	}
+	if code, _, stderr := runCommand(t, "", "rank", path, "nobody"); code != 1 || !strings.Contains(stderr, "not found") {
+		t.Errorf("rank of a missing member = %d %q, expected 1", code, stderr)
+	}
+}
+
+func TestRange(t *testing.T) {
+	path := writeSnapshot(t)
+
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--rank", "1:2"}, "1\tp01\t10\n2\tp02\t20\n"},
+		{[]string{"--rank", "-2:-1", "--rev"}, "10\tp10\t100\n9\tp09\t90\n"},
+		{[]string{"--score", "25:(50"}, "3\tp03\t30\n4\tp04\t40\n"},
+		{[]string{"--score", "(80:100", "--rev"}, "10\tp10\t100\n9\tp09\t90\n"},
+		{[]string{"--score", "-inf:15", "--rev"}, "1\tp01\t10\n"},
+		{[]string{"--score", "-inf:1", "--rev"}, ""},
+	} {
+		args := append(append([]string{"range"}, test.args...), path)
+		if code, out, stderr := runCommand(t, "", args...); code != 0 || out != test.expected {
+			t.Errorf("%v = %d %q %s, expected %q", args, code, out, stderr, test.expected)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"range", path},
+		{"range", "--rank", "1", path},
+		{"range", "--score", "20:10", path},
+		{"range", "--rank", "1:2", "--score", "1:2", path},
+	} {
+		if code, _, _ := runCommand(t, "", args...); code != 2 {
+			t.Errorf("%v = %d, expected 2", args, code)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	path := writeSnapshot(t)
+
+	code, out, _ := runCommand(t, "", "export", path)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if code != 0 || len(lines) != 11 || lines[0] != "rank,key,score,value" || lines[1] != "1,p01,10,player 1" {
+		t.Errorf("export csv = %d %q", code, out)
+	}
+
+	output := filepath.Join(t.TempDir(), "board.json")
+	if code, _, stderr := runCommand(t, "", "export", "--format", "json", "-o", output, path); code != 0 {
+		t.Fatalf("export json = %d %s", code, stderr)
+	}
+	data, _ := os.ReadFile(output)
+	var nodes []map[string]interface{}
+	if err := json.Unmarshal(data, &nodes); err != nil || len(nodes) != 10 || nodes[9]["key"] != "p10" {
+		t.Errorf("exported JSON %s is not the set: %v", data, err)
+	}
+
+	// the exported JSON can be loaded back
+	if code, out, _ := runCommand(t, "", "verify", output); code != 0 || out != "ok: json file, 10 members\n" {
+		t.Errorf("verify exported JSON = %d %q", code, out)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := writeSnapshot(t)
+	if code, out, _ := runCommand(t, "", "verify", path); code != 0 || out != "ok: snapshot file, 10 members\n" {
+		t.Errorf("verify = %d %q", code, out)
+	}
+
+	data, _ := os.ReadFile(path)
+	data[20] ^= 0xff // within the payload
+	os.WriteFile(path, data, 0644)
+	if code, _, stderr := runCommand(t, "", "verify", path); code != 1 || !strings.Contains(stderr, "checksum") {
+		t.Errorf("verify a corrupted snapshot = %d %q, expected a checksum error", code, stderr)
+	}
+}
+
+func TestRDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	a, b := sortedset.New(), sortedset.New()
+	a.AddOrUpdate("x", 1, nil)
+	b.AddOrUpdate("y", 2, nil)
+	if err := rdb.WriteFile(path, map[string]*sortedset.SortedSet{"a": a, "b": b}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _, stderr := runCommand(t, "", "stats", path); code != 2 || !strings.Contains(stderr, "--key: a, b") {
+		t.Errorf("stats of several sets = %d %q, expected to ask for a key", code, stderr)
+	}
+	if code, out, _ := runCommand(t, "", "range", "--key", "b", "--rank", "1:-1", path); code != 0 || out != "1\ty\t2\n" {
+		t.Errorf("range of b = %d %q", code, out)
+	}
+}
+
+func TestREPL(t *testing.T) {
+	path := writeSnapshot(t)
+	stdin := strings.Join([]string{
+		"ZCARD set",
+		"zscore set p03",
+		`ZRANGE set 0 1 WITHSCORES`,
+		`ZADD set 5 "new player"`,
+		"ZRANK set 'new player'",
+		"ZRANGE set 100 200",
+		"ZSCORE set nobody",
+		"GET set",
+		`ZCARD "set`,
+		"quit",
+		"ZCARD set",
+	}, "\n")
+	code, out, _ := runCommand(t, stdin, "repl", path)
+	if code != 0 {
+		t.Fatalf("repl = %d", code)
+	}
+	var replies []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "sortedset> ") {
+			replies = append(replies, strings.TrimPrefix(line, "sortedset> "))
+		} else if len(replies) > 0 {
+			replies[len(replies)-1] += "\n" + line
+		}
+	}
+	expected := []string{
+		"(integer) 10",
+		`"30"`,
+		"1) \"p01\"\n2) \"10\"\n3) \"p02\"\n4) \"20\"",
+		"(integer) 1",
+		"(integer) 0",
+		"(empty array)",
+		"(nil)",
+		"(error) ERR unknown command 'GET'",
+		"(error) unbalanced quotes",
+		"",
+	}
+	if !reflect.DeepEqual(replies, expected) {
+		t.Errorf("repl replies = %q, expected %q", replies, expected)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`  ZADD  key 1 "a \"b\"\n" 'c d'e  `)
+	expected := []string{"ZADD", "key", "1", "a \"b\"\n", "c de"}
+	if err != nil || !reflect.DeepEqual(args, expected) {
+		t.Errorf("splitArgs() = %q %v, expected %q", args, err, expected)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/axieinfinity/sortedset"
+	"github.com/axieinfinity/sortedset/server"
+)
+
+/* sortedset repl <file>
+ * Commands are executed by an in-process server, see package server, and
+ * replies are printed like redis-cli does. */
+func repl(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	sets, format, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(sets))
+	var options []server.Option
+	for key, set := range sets {
+		keys = append(keys, key)
+		options = append(options, server.WithSet(key, set))
+	}
+	sort.Strings(keys)
+	s := server.New(options...)
+	fmt.Fprintf(stdout, "loaded %s file with keys: %s\n", format, strings.Join(keys, ", "))
+	fmt.Fprintln(stdout, `type Z* commands like "ZRANGE set 0 9 WITHSCORES", or "quit"`)
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for {
+		fmt.Fprint(stdout, "sortedset> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			return scanner.Err()
+		}
+		args, err := splitArgs(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(stdout, "(error) %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if name := strings.ToLower(args[0]); name == "quit" || name == "exit" {
+			return nil
+		}
+		fmt.Fprintln(stdout, formatReply(s.Exec(args...)))
+	}
+}
+
+// formatReply format a reply of server.Exec like redis-cli
+func formatReply(reply interface{}) string {
+	switch v := reply.(type) {
+	case nil:
+		return "(nil)"
+	case server.Status:
+		return string(v)
+	case error:
+		return "(error) " + v.Error()
+	case int64:
+		return "(integer) " + strconv.FormatInt(v, 10)
+	case int:
+		return "(integer) " + strconv.Itoa(v)
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.Quote(sortedset.FormatScore(v))
+	case server.Map:
+		return formatArray(v)
+	case []server.Member:
+		items := make([]interface{}, 0, 2*len(v))
+		for _, m := range v {
+			items = append(items, m.Key, m.Score)
+		}
+		return formatArray(items)
+	case []interface{}:
+		return formatArray(v)
+	}
+	return fmt.Sprint(reply)
+}
+
+/* Number the items, indenting the lines of nested arrays. */
+func formatArray(items []interface{}) string {
+	if len(items) == 0 {
+		return "(empty array)"
+	}
+	width := len(strconv.Itoa(len(items)))
+	var b strings.Builder
+	for i, item := range items {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		prefix := fmt.Sprintf("%*d) ", width, i+1)
+		lines := strings.Split(formatReply(item), "\n")
+		for j, line := range lines {
+			if j == 0 {
+				b.WriteString(prefix + line)
+			} else {
+				b.WriteString("\n" + strings.Repeat(" ", len(prefix)) + line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// splitArgs split a command line into arguments separated by spaces, like
+// redis-cli: arguments may be quoted with double quotes supporting escapes
+// or with single quotes
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case ' ', '\t':
+			i++
+			continue
+		}
+
+		var arg strings.Builder
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			switch quote := line[i]; quote {
+			case '"':
+				end := i + 1
+				for end < len(line) && line[end] != '"' {
+					if line[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				if end >= len(line) {
+					return nil, errors.New("unbalanced quotes")
+				}
+				unquoted, err := strconv.Unquote(line[i : end+1])
+				if err != nil {
+					return nil, errors.New("invalid escape in quotes")
+				}
+				arg.WriteString(unquoted)
+				i = end + 1
+			case '\'':
+				end := strings.IndexByte(line[i+1:], '\'')
+				if end < 0 {
+					return nil, errors.New("unbalanced quotes")
+				}
+				arg.WriteString(line[i+1 : i+1+end])
+				i += end + 2
+			default:
+				arg.WriteByte(quote)
+				i++
+			}
+		}
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
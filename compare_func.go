@@ -8,11 +8,26 @@ var greaterThan compareScoreFunc = func(f, f1 float64) bool {
 	return f > f1
 }
 var greaterThanOrEqual compareScoreFunc = func(f, f1 float64) bool {
-	return f > f1 || math.Abs(f-f1) < eps
+	return f > f1 || sameScore(f, f1)
 }
 var lesserThan compareScoreFunc = func(f, f1 float64) bool {
 	return f < f1
 }
 var lesserThanOrEqual compareScoreFunc = func(f, f1 float64) bool {
-	return f < f1 || math.Abs(f-f1) < eps
+	return f < f1 || sameScore(f, f1)
+}
+
+// sameScore report whether two scores are considered equal, within eps of
+// each other. Infinite scores are only equal to themselves.
+func sameScore(f, f1 float64) bool {
+	return f == f1 || math.Abs(f-f1) < eps
+}
+
+// ordered report whether an element with score and key comes before an
+// element with score1 and key1 in a set
+func ordered(score float64, key string, score1 float64, key1 string) bool {
+	if sameScore(score, score1) {
+		return key < key1
+	}
+	return score < score1
 }
@@ -0,0 +1,236 @@
+package sortedset
+
+import (
+	"context"
+	"sync"
+)
+
+// ConcurrentSortedSet is a SortedSet which is safe for concurrent use by
+// multiple goroutines. Every method holds a single lock for its whole
+// duration.
+//
+// On top of the methods of SortedSet, it provides BPopMin and BPopMax which
+// block until an element is available, like BZPOPMIN / BZPOPMAX in Redis.
+type ConcurrentSortedSet struct {
+	mu      sync.Mutex
+	set     *SortedSet
+	waiters []*popWaiter // goroutines blocked in BPopMin / BPopMax, in arrival order
+}
+
+type popWaiter struct {
+	max bool       // pop the element with maximum score instead of minimum
+	ch  chan *Node // receives the popped element, buffered so serving never blocks
+}
+
+// NewConcurrent Create a new ConcurrentSortedSet, see New for the options
+func NewConcurrent(options ...Option) *ConcurrentSortedSet {
+	return &ConcurrentSortedSet{set: New(options...)}
+}
+
+// GetCount Get the number of elements
+func (s *ConcurrentSortedSet) GetCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.GetCount()
+}
+
+// AddOrUpdate Add an element into the set, see SortedSet.AddOrUpdate
+//
+// If goroutines are blocked in BPopMin / BPopMax, the first of them is served
+// before this method returns.
+func (s *ConcurrentSortedSet) AddOrUpdate(key string, score float64, value interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	added := s.set.AddOrUpdate(key, score, value)
+	s.serveWaiters()
+	return added
+}
+
+// IncrBy Increment the score of an element, see SortedSet.IncrBy
+func (s *ConcurrentSortedSet) IncrBy(key string, delta float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	score := s.set.IncrBy(key, delta)
+	s.serveWaiters()
+	return score
+}
+
+// Remove Delete element specified by key, see SortedSet.Remove
+func (s *ConcurrentSortedSet) Remove(key string) *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.Remove(key)
+}
+
+// GetByKey Get node by key, see SortedSet.GetByKey
+func (s *ConcurrentSortedSet) GetByKey(key string) *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.GetByKey(key)
+}
+
+// FindRank Find the rank of the node specified by key, see SortedSet.FindRank
+func (s *ConcurrentSortedSet) FindRank(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.FindRank(key)
+}
+
+// GetByRankRange Get nodes within specific rank range [start, end], see SortedSet.GetByRankRange
+func (s *ConcurrentSortedSet) GetByRankRange(start int, end int, remove bool) []*Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.GetByRankRange(start, end, remove)
+}
+
+// GetByScoreRange Get the nodes whose score within the specific range, see SortedSet.GetByScoreRange
+func (s *ConcurrentSortedSet) GetByScoreRange(minScore float64, maxScore float64, options *GetByScoreRangeOptions) []*Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.GetByScoreRange(minScore, maxScore, options)
+}
+
+// PeekMin get the element with minimum score, nil if the set is empty
+func (s *ConcurrentSortedSet) PeekMin() *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.PeekMin()
+}
+
+// PeekMax get the element with maximum score, nil if the set is empty
+func (s *ConcurrentSortedSet) PeekMax() *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.PeekMax()
+}
+
+// PopMin get and remove the element with minimal score, nil if the set is empty
+func (s *ConcurrentSortedSet) PopMin() *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.PopMin()
+}
+
+// PopMax get and remove the element with maximum score, nil if the set is empty
+func (s *ConcurrentSortedSet) PopMax() *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.PopMax()
+}
+
+// BPopMin get and remove the element with minimal score, blocking until the
+// set is not empty or ctx is done. In the latter case ctx.Err() is returned.
+//
+// Blocked goroutines are served in the order they called BPopMin / BPopMax.
+func (s *ConcurrentSortedSet) BPopMin(ctx context.Context) (*Node, error) {
+	return s.bpop(ctx, false)
+}
+
+// BPopMax get and remove the element with maximum score, blocking until the
+// set is not empty or ctx is done. In the latter case ctx.Err() is returned.
+//
+// Blocked goroutines are served in the order they called BPopMin / BPopMax.
+func (s *ConcurrentSortedSet) BPopMax(ctx context.Context) (*Node, error) {
+	return s.bpop(ctx, true)
+}
+
+func (s *ConcurrentSortedSet) bpop(ctx context.Context, max bool) (*Node, error) {
+	s.mu.Lock()
+	// nobody can be waiting while the set is not empty, so popping here does
+	// not overtake an earlier waiter
+	if s.set.GetCount() > 0 {
+		node := s.pop(max)
+		s.mu.Unlock()
+		return node, nil
+	}
+	w := &popWaiter{max: max, ch: make(chan *Node, 1)}
+	s.waiters = append(s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case node := <-w.ch:
+		return node, nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, waiter := range s.waiters {
+		if waiter == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return nil, ctx.Err()
+		}
+	}
+	// the waiter was served while ctx was being cancelled, do not lose the element
+	return <-w.ch, nil
+}
+
+func (s *ConcurrentSortedSet) pop(max bool) *Node {
+	if max {
+		return s.set.PopMax()
+	}
+	return s.set.PopMin()
+}
+
+/* Hand elements to blocked goroutines, the lock must be held. */
+func (s *ConcurrentSortedSet) serveWaiters() {
+	for len(s.waiters) > 0 && s.set.GetCount() > 0 {
+		w := s.waiters[0]
+		s.waiters[0] = nil
+		s.waiters = s.waiters[1:]
+		w.ch <- s.pop(w.max)
+	}
+}
+
+// Subscribe register fn to be called with every change of the set, see
+// SortedSet.Subscribe. fn is called with the lock held, so it must not call
+// any method of the set.
+func (s *ConcurrentSortedSet) Subscribe(fn func(e Event)) *Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := s.set.Subscribe(fn)
+	sub.mu = &s.mu
+	return sub
+}
+
+// SubscribeChan Create a channel receiving every change of the set, see
+// SortedSet.SubscribeChan. Event.Node must not be used by the receiver.
+func (s *ConcurrentSortedSet) SubscribeChan(size int) (<-chan Event, *Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, sub := s.set.SubscribeChan(size)
+	sub.mu = &s.mu
+	return ch, sub
+}
+
+// Watch register fn to be called every time the rank of key changes, see
+// SortedSet.Watch. fn is called with the lock held, so it must not call any
+// method of the set.
+func (s *ConcurrentSortedSet) Watch(key string, fn func(c RankChange)) *Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := s.set.Watch(key, fn)
+	sub.mu = &s.mu
+	return sub
+}
+
+// AddIndex Register a secondary index, see SortedSet.AddIndex
+func (s *ConcurrentSortedSet) AddIndex(name string, extract func(value interface{}) (group string, ok bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.AddIndex(name, extract)
+}
+
+// FindRankIn Find the rank of the node specified by key within a group, see SortedSet.FindRankIn
+func (s *ConcurrentSortedSet) FindRankIn(name string, group string, key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.FindRankIn(name, group, key)
+}
+
+// GetByRankRangeIn Get nodes of a group within specific rank range [start, end], see SortedSet.GetByRankRangeIn
+func (s *ConcurrentSortedSet) GetByRankRangeIn(name string, group string, start int, end int) []*Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.GetByRankRangeIn(name, group, start, end)
+}
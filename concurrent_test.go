@@ -0,0 +1,89 @@
+package sortedset
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBPopMin(t *testing.T) {
+	sortedset := NewConcurrent()
+	sortedset.AddOrUpdate("a", 1, nil)
+
+	node, err := sortedset.BPopMin(context.Background())
+	if err != nil || node == nil || node.Key() != "a" {
+		t.Fatalf("BPopMin() on a non-empty set should return `a` immediately, got %v, %v", node, err)
+	}
+
+	result := make(chan *Node)
+	go func() {
+		node, _ := sortedset.BPopMax(context.Background())
+		result <- node
+	}()
+
+	select {
+	case <-result:
+		t.Fatal("BPopMax() on an empty set should block")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sortedset.AddOrUpdate("b", 2, nil)
+	if node := <-result; node == nil || node.Key() != "b" {
+		t.Errorf("BPopMax() should return `b` once it is added, got %v", node)
+	}
+	if sortedset.GetCount() != 0 {
+		t.Error("the element handed to a blocked goroutine should be removed")
+	}
+}
+
+func TestBPopMinCancel(t *testing.T) {
+	sortedset := NewConcurrent()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	node, err := sortedset.BPopMin(ctx)
+	if node != nil || err != context.DeadlineExceeded {
+		t.Errorf("BPopMin() should return the context error, got %v, %v", node, err)
+	}
+
+	// the cancelled waiter must not swallow the next element
+	sortedset.AddOrUpdate("a", 1, nil)
+	if sortedset.GetCount() != 1 {
+		t.Error("a cancelled waiter should not be served")
+	}
+}
+
+func TestBPopMinFairness(t *testing.T) {
+	sortedset := NewConcurrent()
+
+	const waiters = 5
+	results := make([]chan *Node, waiters)
+	for i := 0; i < waiters; i++ {
+		results[i] = make(chan *Node, 1)
+		go func(ch chan *Node) {
+			node, _ := sortedset.BPopMin(context.Background())
+			ch <- node
+		}(results[i])
+		// wait until the goroutine is queued so the arrival order is known
+		for {
+			sortedset.mu.Lock()
+			queued := len(sortedset.waiters)
+			sortedset.mu.Unlock()
+			if queued == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for i := 0; i < waiters; i++ {
+		sortedset.AddOrUpdate(fmt.Sprint(i), float64(i), nil)
+	}
+
+	for i := 0; i < waiters; i++ {
+		if node := <-results[i]; node.Key() != fmt.Sprint(i) {
+			t.Errorf("waiter %d received %q, expected %q", i, node.Key(), fmt.Sprint(i))
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Package crdt provides a sorted set which can be changed by several replicas
+// independently, e.g. game servers of different regions accepting scores
+// while disconnected, and merged later without conflicts.
+//
+// Set is a last-writer-wins element set: every key carries the timestamp of
+// its last change, given by a hybrid logical clock, and removed keys are kept
+// as tombstones. Merging keeps the latest change of every key, so Merge is
+// commutative, associative and idempotent: replicas which merged the same
+// changes, in any order and any number of times, hold the same elements.
+//
+//	eu := crdt.New("eu")
+//	us := crdt.New("us")
+//	eu.AddOrUpdate("alice", 100, nil)
+//	us.AddOrUpdate("alice", 120, nil)
+//	us.Remove("bob")
+//
+//	data, _ := us.MarshalBinary() // sent to eu
+//	remote := crdt.New("us")
+//	remote.UnmarshalBinary(data)
+//	eu.Merge(remote)
+//	eu.FindRank("alice")
+//
+// Like SortedSet, a Set is not safe for concurrent use.
+package crdt
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+const (
+	binaryMagic   = "SCRD"
+	binaryVersion = 1
+)
+
+// ErrInvalidData is returned by UnmarshalBinary when the data is truncated or malformed
+var ErrInvalidData = errors.New("crdt: invalid binary data")
+
+var (
+	_ encoding.BinaryMarshaler   = (*Set)(nil)
+	_ encoding.BinaryUnmarshaler = (*Set)(nil)
+)
+
+// Timestamp is the time of a change given by a hybrid logical clock: the
+// wall clock of the replica, a counter ordering changes made within the same
+// wall clock time or after observing changes from the future, and the name
+// of the replica, so timestamps of different replicas are never equal.
+type Timestamp struct {
+	Wall    int64 // Unix time in nanoseconds
+	Logical uint32
+	Replica string
+}
+
+// Less report whether t is before u
+func (t Timestamp) Less(u Timestamp) bool {
+	if t.Wall != u.Wall {
+		return t.Wall < u.Wall
+	}
+	if t.Logical != u.Logical {
+		return t.Logical < u.Logical
+	}
+	return t.Replica < u.Replica
+}
+
+// Option configures a Set created by New
+type Option func(s *Set)
+
+// WithClock make the Set read the wall clock with now instead of time.Now
+func WithClock(now func() time.Time) Option {
+	return func(s *Set) {
+		s.now = now
+	}
+}
+
+// WithValueCodec make MarshalBinary and UnmarshalBinary encode values with
+// codec instead of sortedset.GobCodec
+func WithValueCodec(codec sortedset.ValueCodec) Option {
+	return func(s *Set) {
+		s.codec = codec
+	}
+}
+
+// Set is a sorted set replicated by merging, see the package documentation
+type Set struct {
+	replica string
+	now     func() time.Time
+	codec   sortedset.ValueCodec
+	last    Timestamp            // latest timestamp given or observed
+	set     *sortedset.SortedSet // elements which are not removed
+	entries map[string]*entry    // last change of every key, including removals
+}
+
+// entry is the last change of a key
+type entry struct {
+	ts      Timestamp
+	removed bool
+	score   float64
+	value   interface{}
+}
+
+// New Create an empty Set changed by the replica named replica. Every
+// replica must have a distinct name.
+func New(replica string, options ...Option) *Set {
+	s := &Set{
+		replica: replica,
+		now:     time.Now,
+		codec:   sortedset.GobCodec{},
+		set:     sortedset.New(),
+		entries: make(map[string]*entry),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+/* Timestamp of a new change, after every timestamp given or observed. */
+func (s *Set) tick() Timestamp {
+	wall := s.now().UnixNano()
+	if wall > s.last.Wall {
+		s.last = Timestamp{Wall: wall}
+	} else {
+		s.last.Logical++
+	}
+	s.last.Replica = s.replica
+	return s.last
+}
+
+/* Move the clock after a timestamp of another replica. */
+func (s *Set) observe(ts Timestamp) {
+	if ts.Wall > s.last.Wall || ts.Wall == s.last.Wall && ts.Logical > s.last.Logical {
+		s.last.Wall, s.last.Logical = ts.Wall, ts.Logical
+	}
+}
+
+// AddOrUpdate Add an element, or update its score and value, and return the
+// timestamp of the change
+//
+// Time complexity of this method is : O(log(N))
+func (s *Set) AddOrUpdate(key string, score float64, value interface{}) Timestamp {
+	e := &entry{ts: s.tick(), score: score, value: value}
+	s.apply(key, e)
+	return e.ts
+}
+
+// Remove Delete the element specified by key, and return the timestamp of
+// the change. The key is remembered as removed, so merging an older change
+// of the key does not add it again.
+//
+// Time complexity of this method is : O(log(N))
+func (s *Set) Remove(key string) Timestamp {
+	e := &entry{ts: s.tick(), removed: true}
+	s.apply(key, e)
+	return e.ts
+}
+
+func (s *Set) apply(key string, e *entry) {
+	s.entries[key] = e
+	if e.removed {
+		s.set.Remove(key)
+		return
+	}
+	// AddOrUpdate keeps a score within eps of the new one, replicas must hold
+	// the very score of the last change to converge
+	if node := s.set.GetByKey(key); node != nil && math.Float64bits(node.Score()) != math.Float64bits(e.score) {
+		s.set.Remove(key)
+	}
+	s.set.AddOrUpdate(key, e.score, e.value)
+}
+
+// Merge Apply the changes of other which are more recent than the ones of
+// the set, key by key. Later changes of the set are timestamped after every
+// change of other.
+//
+// Time complexity of this method is : O(M*log(N)) for M keys in other
+func (s *Set) Merge(other *Set) {
+	for key, theirs := range other.entries {
+		if ours := s.entries[key]; ours == nil || ours.ts.Less(theirs.ts) {
+			s.apply(key, theirs)
+		}
+		s.observe(theirs.ts)
+	}
+}
+
+// Timestamp Get the timestamp of the last change of key, and whether key was
+// ever changed. A removed key has the timestamp of its removal.
+func (s *Set) Timestamp(key string) (Timestamp, bool) {
+	if e := s.entries[key]; e != nil {
+		return e.ts, true
+	}
+	return Timestamp{}, false
+}
+
+// GetCount Get the number of elements, see SortedSet.GetCount
+func (s *Set) GetCount() int {
+	return s.set.GetCount()
+}
+
+// GetByKey Get node by key, see SortedSet.GetByKey
+func (s *Set) GetByKey(key string) *sortedset.Node {
+	return s.set.GetByKey(key)
+}
+
+// FindRank Find the rank of the node specified by key, see SortedSet.FindRank
+func (s *Set) FindRank(key string) int {
+	return s.set.FindRank(key)
+}
+
+// GetByRank Get the node at rank, see SortedSet.GetByRank
+func (s *Set) GetByRank(rank int) *sortedset.Node {
+	return s.set.GetByRank(rank, false)
+}
+
+// GetByRankRange Get nodes within specific rank range [start, end], see SortedSet.GetByRankRange
+func (s *Set) GetByRankRange(start int, end int) []*sortedset.Node {
+	return s.set.GetByRankRange(start, end, false)
+}
+
+// GetByScoreRange Get the nodes whose score within the specific range, see SortedSet.GetByScoreRange
+func (s *Set) GetByScoreRange(minScore float64, maxScore float64, options *sortedset.GetByScoreRangeOptions) []*sortedset.Node {
+	return s.set.GetByScoreRange(minScore, maxScore, options)
+}
+
+// PeekMin get the element with minimum score, nil if the set is empty
+func (s *Set) PeekMin() *sortedset.Node {
+	return s.set.PeekMin()
+}
+
+// PeekMax get the element with maximum score, nil if the set is empty
+func (s *Set) PeekMax() *sortedset.Node {
+	return s.set.PeekMax()
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+//
+// Every key is encoded with the timestamp of its last change, including the
+// removed ones, so the result can be merged by another replica after
+// UnmarshalBinary.
+//
+// Time complexity of this method is : O(N)
+func (s *Set) MarshalBinary() ([]byte, error) {
+	buf := append([]byte(binaryMagic), binaryVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(s.entries)))
+	for key, e := range s.entries {
+		buf = appendString(buf, key)
+		buf = binary.AppendVarint(buf, e.ts.Wall)
+		buf = binary.AppendUvarint(buf, uint64(e.ts.Logical))
+		buf = appendString(buf, e.ts.Replica)
+		if e.removed {
+			buf = append(buf, 0)
+			continue
+		}
+		if e.value == nil {
+			buf = append(buf, 1)
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(e.score))
+			continue
+		}
+		data, err := s.codec.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, 2)
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(e.score))
+		buf = appendString(buf, string(data))
+	}
+	return buf, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+//
+// The elements and timestamps of the set are replaced by the ones in data,
+// its replica name and options are kept.
+//
+// Time complexity of this method is : O(N*log(N))
+func (s *Set) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binaryMagic)+1 || string(data[:len(binaryMagic)]) != binaryMagic || data[len(binaryMagic)] != binaryVersion {
+		return ErrInvalidData
+	}
+	r := reader{data: data[len(binaryMagic)+1:]}
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		return ErrInvalidData
+	}
+	entries := make(map[string]*entry, n)
+	var batch []sortedset.Entry
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		key := r.string()
+		e := &entry{}
+		e.ts.Wall = r.varint()
+		e.ts.Logical = uint32(r.uvarint())
+		e.ts.Replica = r.string()
+		switch r.byte() {
+		case 0:
+			e.removed = true
+		case 1:
+			e.score = math.Float64frombits(r.uint64())
+		case 2:
+			e.score = math.Float64frombits(r.uint64())
+			if r.err == nil {
+				value, err := s.codec.Unmarshal([]byte(r.string()))
+				if err != nil {
+					return err
+				}
+				e.value = value
+			}
+		default:
+			return ErrInvalidData
+		}
+		entries[key] = e
+		if !e.removed {
+			batch = append(batch, sortedset.Entry{Key: key, Score: e.score, Value: e.value})
+		}
+	}
+	if r.err != nil || len(r.data) != 0 || len(entries) != int(n) {
+		return ErrInvalidData
+	}
+
+	s.entries = entries
+	s.set = sortedset.New()
+	s.set.AddOrUpdateBatch(batch)
+	for _, e := range entries {
+		s.observe(e.ts)
+	}
+	return nil
+}
+
+// reader decodes the binary format, remembering the first error
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidData
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidData
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	n := r.uvarint()
+	if r.err != nil || n > uint64(len(r.data)) {
+		r.err = ErrInvalidData
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *reader) uint64() uint64 {
+	if r.err != nil || len(r.data) < 8 {
+		r.err = ErrInvalidData
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil || len(r.data) < 1 {
+		r.err = ErrInvalidData
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
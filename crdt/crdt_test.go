@@ -0,0 +1,162 @@
+package crdt
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+/* A clock frozen at the same instant for every replica. */
+func frozen() Option {
+	now := time.Unix(1700000000, 0)
+	return WithClock(func() time.Time { return now })
+}
+
+/* Check that two replicas hold the same elements and timestamps. */
+func checkConverged(t *testing.T, a, b *Set) {
+	t.Helper()
+	if !a.set.Equal(b.set, func(x, y interface{}) bool { return x == y }) {
+		t.Errorf("%s and %s hold different elements", a.replica, b.replica)
+	}
+	if len(a.entries) != len(b.entries) {
+		t.Fatalf("%s and %s know %d and %d keys", a.replica, b.replica, len(a.entries), len(b.entries))
+	}
+	for key, e := range a.entries {
+		if other := b.entries[key]; other == nil || *other != *e {
+			t.Errorf("last change of %q is %v in %s and %v in %s", key, e, a.replica, other, b.replica)
+		}
+	}
+}
+
+func TestLastWriterWins(t *testing.T) {
+	eu, us := New("eu", frozen()), New("us", frozen())
+	eu.AddOrUpdate("alice", 100, "eu")
+	us.AddOrUpdate("alice", 120, "us") // same wall time, us wins by its name
+	us.AddOrUpdate("bob", 50, nil)
+	eu.Remove("bob") // concurrent with the addition by us, which wins by its name again
+
+	eu.Merge(us)
+	if node := eu.GetByKey("alice"); node == nil || node.Score() != 120 || node.Value != "us" {
+		t.Errorf("alice is %v in eu, expected the score of us", node)
+	}
+	if eu.GetByKey("bob") == nil {
+		t.Error("the addition of bob by us should win over its removal by eu")
+	}
+
+	// changes made after a merge win over the merged ones
+	eu.Remove("bob")
+	us.Merge(eu)
+	if us.GetByKey("bob") != nil || us.GetCount() != 1 {
+		t.Error("bob was removed by eu after merging, it should not be in us")
+	}
+	checkConverged(t, eu, us)
+
+	// an older addition does not resurrect a removed key
+	old := New("old", WithClock(func() time.Time { return time.Unix(0, 0) }))
+	old.AddOrUpdate("bob", 1000, nil)
+	us.Merge(old)
+	if us.GetByKey("bob") != nil {
+		t.Error("an older addition resurrected bob")
+	}
+	if ts, ok := us.Timestamp("bob"); !ok || ts.Replica != "eu" {
+		t.Errorf("last change of bob is %v, expected the removal by eu", ts)
+	}
+}
+
+func TestMergeCloseScores(t *testing.T) {
+	eu, us := New("eu", frozen()), New("us", frozen())
+	eu.AddOrUpdate("alice", 100, nil)
+	us.Merge(eu)
+	us.AddOrUpdate("alice", 100.000001, nil) // within eps of the score of eu
+	eu.Merge(us)
+	for _, s := range []*Set{eu, us} {
+		if node := s.GetByKey("alice"); node == nil || node.Score() != 100.000001 {
+			t.Errorf("alice is %v in %s, expected the score of the last change", node, s.replica)
+		}
+	}
+	checkConverged(t, eu, us)
+}
+
+func TestMergeProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var clock int64
+	now := func() time.Time {
+		clock += r.Int63n(3) - 1 // clocks going backward are handled by the logical counter
+		return time.Unix(1700000000, clock)
+	}
+	replicas := []*Set{New("a", WithClock(now)), New("b", WithClock(now)), New("c", WithClock(now))}
+	for i := 0; i < 3000; i++ {
+		s := replicas[r.Intn(len(replicas))]
+		key := fmt.Sprint("key", r.Intn(50))
+		switch r.Intn(10) {
+		case 0:
+			s.Remove(key)
+		case 1:
+			s.Merge(replicas[r.Intn(len(replicas))])
+		default:
+			s.AddOrUpdate(key, float64(r.Intn(100)), r.Intn(5))
+		}
+	}
+
+	merge := func(sets ...*Set) *Set {
+		result := New("result")
+		for _, s := range sets {
+			result.Merge(s)
+		}
+		return result
+	}
+	a, b, c := replicas[0], replicas[1], replicas[2]
+
+	// commutative
+	checkConverged(t, merge(a, b), merge(b, a))
+	// associative
+	ab := merge(a, b)
+	bc := merge(b, c)
+	checkConverged(t, merge(ab, c), merge(a, bc))
+	// idempotent
+	checkConverged(t, merge(a, a, b, a, b), merge(a, b))
+
+	for _, s := range replicas {
+		for _, other := range replicas {
+			s.Merge(other)
+		}
+	}
+	checkConverged(t, a, b)
+	checkConverged(t, b, c)
+	for i, node := range a.GetByRankRange(1, -1) {
+		if a.FindRank(node.Key()) != i+1 {
+			t.Errorf("rank of %q is %d, expected %d", node.Key(), a.FindRank(node.Key()), i+1)
+		}
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	s := New("eu")
+	s.AddOrUpdate("alice", 100, "value")
+	s.AddOrUpdate("bob", 50, nil)
+	s.AddOrUpdate("carol", 70, 3)
+	s.Remove("carol")
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New("us")
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	checkConverged(t, s, loaded)
+	if loaded.replica != "us" {
+		t.Error("the replica name was not kept")
+	}
+	if ts := loaded.AddOrUpdate("dave", 1, nil); !s.last.Less(ts) {
+		t.Errorf("change %v after loading is not after the loaded changes %v", ts, s.last)
+	}
+
+	for i := 0; i < len(data); i++ {
+		if err := New("x").UnmarshalBinary(data[:i]); err == nil {
+			t.Errorf("truncated data of %d bytes was loaded", i)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package sortedset
+
+import "math"
+
+// Digest Get a hash of the keys and scores of the set, values being ignored.
+// Two sets holding the same keys with the same scores have the same digest,
+// whatever the order of the changes which filled them. It is kept up to date
+// by every change, so it can be compared after every change to check that a
+// replica did not diverge.
+//
+// Time complexity of this method is : O(1)
+func (set *SortedSet) Digest() uint64 {
+	set.evictExpired()
+	return set.digest
+}
+
+/* The hash of an element, the digest of a set being the sum of the hashes of
+ * its elements so it can be updated by every insertion and deletion. */
+func elementHash(key string, score float64) uint64 {
+	if score == 0 {
+		score = 0 // -0 and 0 are the same score
+	}
+	// FNV-1a of the key then the bits of the score
+	const prime = 1099511628211
+	x := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		x = (x ^ uint64(key[i])) * prime
+	}
+	bits := math.Float64bits(score)
+	for i := 0; i < 64; i += 8 {
+		x = (x ^ (bits >> i & 0xff)) * prime
+	}
+
+	// finalizer of splitmix64, so the sums of different elements do not collide easily
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Bound is a position in a set, before the element with Score and Key if
+// there is one. Elements ordered before the bound are before it, see
+// RangeDigest.
+type Bound struct {
+	Score float64
+	Key   string
+}
+
+// RangeDigest is the number of elements of a set within [From, To) and the
+// sum of their hashes, see Digest. A nil From means the range starts with
+// the first element, a nil To that it ends with the last element.
+type RangeDigest struct {
+	From   *Bound
+	To     *Bound
+	Count  int
+	Digest uint64
+}
+
+// Summarize Split the elements within [from, to) into up to parts ranges of
+// about the same number of elements, and return their digests. The ranges
+// cover the whole of [from, to), they start with from and end with to, and
+// they are bounded by elements of the set, so another set can compute the
+// digests of the same ranges with DigestRange and find where the sets
+// differ, like comparing the children of a node of a Merkle tree.
+//
+// An empty range is returned if no element is within [from, to).
+//
+// Time complexity of this method is : O(log(N) + M) for M elements within the range
+func (set *SortedSet) Summarize(from, to *Bound, parts int) []RangeDigest {
+	set.evictExpired()
+	if parts < 1 {
+		parts = 1
+	}
+	var nodes []*Node
+	for x := set.seek(from); x != nil && to.after(x); x = x.level[0].forward {
+		nodes = append(nodes, x)
+	}
+	if len(nodes) == 0 {
+		return []RangeDigest{{From: from, To: to}}
+	}
+	if parts > len(nodes) {
+		parts = len(nodes)
+	}
+
+	ranges := make([]RangeDigest, 0, parts)
+	start := 0
+	for i := 1; i <= parts; i++ {
+		end := i * len(nodes) / parts
+		r := RangeDigest{From: from, To: to, Count: end - start}
+		if start > 0 {
+			r.From = &Bound{Score: nodes[start].score, Key: nodes[start].key}
+		}
+		if end < len(nodes) {
+			r.To = &Bound{Score: nodes[end].score, Key: nodes[end].key}
+		}
+		for _, x := range nodes[start:end] {
+			r.Digest += elementHash(x.key, x.score)
+		}
+		ranges = append(ranges, r)
+		start = end
+	}
+	return ranges
+}
+
+// DigestRange Get the number and the digest of the elements within [from, to)
+//
+// Time complexity of this method is : O(log(N) + M) for M elements within the range
+func (set *SortedSet) DigestRange(from, to *Bound) RangeDigest {
+	set.evictExpired()
+	r := RangeDigest{From: from, To: to}
+	for x := set.seek(from); x != nil && to.after(x); x = x.level[0].forward {
+		r.Count++
+		r.Digest += elementHash(x.key, x.score)
+	}
+	return r
+}
+
+// RangeEntries Get the elements within [from, to), in rank order
+//
+// Time complexity of this method is : O(log(N) + M) for M elements within the range
+func (set *SortedSet) RangeEntries(from, to *Bound) []Entry {
+	set.evictExpired()
+	var entries []Entry
+	for x := set.seek(from); x != nil && to.after(x); x = x.level[0].forward {
+		entries = append(entries, Entry{Key: x.key, Score: x.score, Value: x.Value})
+	}
+	return entries
+}
+
+/* First node which is not before b, the first node of the set if b is nil. */
+func (set *SortedSet) seek(b *Bound) *Node {
+	x := set.header
+	if b != nil {
+		for i := set.level - 1; i >= 0; i-- {
+			for x.level[i].forward != nil && b.after(x.level[i].forward) {
+				x = x.level[i].forward
+			}
+		}
+	}
+	return x.level[0].forward
+}
+
+/* Whether node x is before the bound, a nil bound being after every node. */
+func (b *Bound) after(x *Node) bool {
+	return b == nil || ordered(x.score, x.key, b.Score, b.Key)
+}
+
+// DigestSource gives the digests and the elements of another set to
+// Reconcile, e.g. by asking them over the network to a process holding the
+// set. See NewDigestSource for a set of the same process.
+type DigestSource interface {
+	// Summarize returns the digests of SortedSet.Summarize for the other set
+	Summarize(from, to *Bound, parts int) ([]RangeDigest, error)
+	// RangeEntries returns the elements of the other set within [from, to)
+	RangeEntries(from, to *Bound) ([]Entry, error)
+}
+
+// NewDigestSource Create a DigestSource answering with the content of set
+func NewDigestSource(set *SortedSet) DigestSource {
+	return setSource{set}
+}
+
+type setSource struct {
+	set *SortedSet
+}
+
+func (s setSource) Summarize(from, to *Bound, parts int) ([]RangeDigest, error) {
+	return s.set.Summarize(from, to, parts), nil
+}
+
+func (s setSource) RangeEntries(from, to *Bound) ([]Entry, error) {
+	return s.set.RangeEntries(from, to), nil
+}
+
+// Patch is a list of changes turning a set into another one, see Reconcile
+type Patch struct {
+	Set    []Entry  // elements to add, or whose score differs
+	Remove []string // keys to remove
+}
+
+// Reconcile Compute the patch turning the set into the other set given by
+// remote, exchanging digests instead of elements wherever the sets agree.
+// Ranges of the other set are split into parts ranges, see Summarize, and
+// only the ranges whose digest differs are split again, until they hold no
+// more than leafSize elements of the other set, which are then fetched and
+// compared with the set.
+//
+// Only keys and scores are compared, values are ignored: Patch.Set holds the
+// elements which are missing from the set or have another score, with their
+// value in the other set.
+//
+// Only about D*leafSize elements of the other set are fetched for D
+// differences, but the digests of the set are computed over its ranges at
+// every level of splitting.
+//
+// Time complexity of this method is : O(N*log(N)) in the worst case, O(N) when differences are few
+func (set *SortedSet) Reconcile(remote DigestSource, parts int, leafSize int) (Patch, error) {
+	set.evictExpired()
+	if parts < 2 {
+		parts = 2
+	}
+	var patch Patch
+	setKeys := make(map[string]bool)
+	var removed []string
+
+	queue := []RangeDigest{{}}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		summary, err := remote.Summarize(r.From, r.To, parts)
+		if err != nil {
+			return Patch{}, err
+		}
+		for _, theirs := range summary {
+			ours := set.DigestRange(theirs.From, theirs.To)
+			if ours.Count == theirs.Count && ours.Digest == theirs.Digest {
+				continue
+			}
+			if theirs.Count > leafSize && len(summary) > 1 {
+				queue = append(queue, theirs)
+				continue
+			}
+
+			entries, err := remote.RangeEntries(theirs.From, theirs.To)
+			if err != nil {
+				return Patch{}, err
+			}
+			keys := make(map[string]bool, len(entries))
+			for _, e := range entries {
+				keys[e.Key] = true
+				if x := set.dict[e.Key]; x == nil || !sameScore(x.score, e.Score) {
+					patch.Set = append(patch.Set, e)
+					setKeys[e.Key] = true
+				}
+			}
+			for x := set.seek(theirs.From); x != nil && theirs.To.after(x); x = x.level[0].forward {
+				if !keys[x.key] {
+					removed = append(removed, x.key)
+				}
+			}
+		}
+	}
+
+	// keys found in another range of the other set are moved, not removed
+	for _, key := range removed {
+		if !setKeys[key] {
+			patch.Remove = append(patch.Remove, key)
+		}
+	}
+	return patch, nil
+}
+
+// ApplyPatch Remove then add or update the elements of the patch
+//
+// Time complexity of this method is : O(M*log(N)) for M changes
+func (set *SortedSet) ApplyPatch(patch Patch) {
+	for _, key := range patch.Remove {
+		set.Remove(key)
+	}
+	set.AddOrUpdateBatch(patch.Set)
+}
@@ -0,0 +1,161 @@
+package sortedset
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+/* A DigestSource counting the elements it sends. */
+type countingSource struct {
+	DigestSource
+	entries int
+}
+
+func (s *countingSource) RangeEntries(from, to *Bound) ([]Entry, error) {
+	entries, err := s.DigestSource.RangeEntries(from, to)
+	s.entries += len(entries)
+	return entries, err
+}
+
+func TestDigest(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 100; i++ {
+		a.AddOrUpdate(fmt.Sprint("key", i), float64(i%10), i)
+	}
+	for i := 99; i >= 0; i-- {
+		b.AddOrUpdate(fmt.Sprint("key", i), float64(i), nil)
+		b.AddOrUpdate(fmt.Sprint("key", i), float64(i%10), nil)
+	}
+	if a.Digest() != b.Digest() {
+		t.Error("sets with the same keys and scores have different digests")
+	}
+	if a.Clone(nil).Digest() != a.Digest() {
+		t.Error("the clone has another digest")
+	}
+	data, _ := a.MarshalBinary()
+	loaded := New()
+	loaded.UnmarshalBinary(data)
+	if loaded.Digest() != a.Digest() {
+		t.Error("the unmarshaled set has another digest")
+	}
+
+	digest := b.Digest()
+	b.IncrBy("key5", 1)
+	if b.Digest() == digest {
+		t.Error("changing a score does not change the digest")
+	}
+	b.IncrBy("key5", -1)
+	b.Remove("key6")
+	b.AddOrUpdate("key6", 6, "value")
+	if b.Digest() != digest {
+		t.Error("undoing the changes does not restore the digest")
+	}
+
+	tx := b.Begin()
+	b.PopMin()
+	b.AddOrUpdate("new", 1, nil)
+	tx.Rollback()
+	if b.Digest() != digest {
+		t.Error("rolling back does not restore the digest")
+	}
+	b.GetByRankRange(1, -1, true)
+	if b.Digest() != 0 || New().Digest() != 0 {
+		t.Error("the digest of an empty set is not 0")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	set := New()
+	for i := 0; i < 10; i++ {
+		set.AddOrUpdate(fmt.Sprint("key", i), float64(i/2), nil)
+	}
+	ranges := set.Summarize(nil, nil, 3)
+	if len(ranges) != 3 || ranges[0].From != nil || ranges[2].To != nil {
+		t.Fatalf("Summarize() = %v, expected 3 ranges covering the set", ranges)
+	}
+	total := 0
+	var digest uint64
+	for i, r := range ranges {
+		if i > 0 && *r.From != *ranges[i-1].To {
+			t.Errorf("range %d does not start where the previous one ends", i)
+		}
+		if actual := set.DigestRange(r.From, r.To); actual.Count != r.Count || actual.Digest != r.Digest {
+			t.Errorf("DigestRange() of range %d = %v, expected %v", i, actual, r)
+		}
+		total += r.Count
+		digest += r.Digest
+	}
+	if total != 10 || digest != set.Digest() {
+		t.Errorf("ranges hold %d elements, expected 10", total)
+	}
+	if *ranges[1].From != (Bound{Score: 1, Key: "key3"}) {
+		t.Errorf("second range starts at %v, expected the 4th element", *ranges[1].From)
+	}
+
+	from, to := &Bound{Score: 1, Key: "key3"}, &Bound{Score: 3, Key: "key6"}
+	checkOrder(t, nodesOf(set, set.RangeEntries(from, to)), []string{"key3", "key4", "key5"})
+	if r := set.Summarize(to, from, 2); len(r) != 1 || r[0].Count != 0 {
+		t.Errorf("Summarize() of an empty range = %v", r)
+	}
+}
+
+func nodesOf(set *SortedSet, entries []Entry) []*Node {
+	nodes := make([]*Node, len(entries))
+	for i, e := range entries {
+		nodes[i] = set.GetByKey(e.Key)
+	}
+	return nodes
+}
+
+func TestReconcile(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	leader := New()
+	for i := 0; i < 10000; i++ {
+		leader.AddOrUpdate(fmt.Sprint("key", i), float64(r.Intn(1000)), i)
+	}
+	replica := leader.Clone(nil)
+	replica.Remove("key10")
+	replica.IncrBy("key20", 1)
+	replica.AddOrUpdate("stale", 500, nil)
+	leader.AddOrUpdate("key30", 5000, 30)
+	leader.AddOrUpdate("added", 1, nil)
+
+	source := &countingSource{DigestSource: NewDigestSource(leader)}
+	patch, err := replica.Reconcile(source, 16, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set []string
+	for _, e := range patch.Set {
+		set = append(set, e.Key)
+	}
+	sort.Strings(set)
+	if fmt.Sprint(set) != "[added key10 key20 key30]" || fmt.Sprint(patch.Remove) != "[stale]" {
+		t.Errorf("patch sets %v and removes %v", set, patch.Remove)
+	}
+	if source.entries > 5*8 {
+		t.Errorf("%d elements were fetched to find 5 differences", source.entries)
+	}
+
+	replica.ApplyPatch(patch)
+	if replica.Digest() != leader.Digest() || !replica.Equal(leader, nil) {
+		t.Error("the patched replica is not equal to the leader")
+	}
+	if patch, _ := replica.Reconcile(source, 16, 8); len(patch.Set)+len(patch.Remove) != 0 {
+		t.Errorf("patch between equal sets is %v", patch)
+	}
+
+	// reconcile with an empty set and from an empty set
+	empty := New()
+	patch, _ = leader.Reconcile(NewDigestSource(empty), 4, 4)
+	if len(patch.Set) != 0 || len(patch.Remove) != leader.GetCount() {
+		t.Errorf("patch to an empty set sets %d and removes %d elements", len(patch.Set), len(patch.Remove))
+	}
+	patch, _ = empty.Reconcile(NewDigestSource(leader), 4, 4)
+	empty.ApplyPatch(patch)
+	if !empty.Equal(leader, nil) {
+		t.Error("the patched empty set is not equal to the leader")
+	}
+}
@@ -126,5 +126,36 @@ Examples
 	    ExcludeStart: true,
 	    ExcludeEnd: true,
 	})
+
+	// sum the scores of daily sets into a weekly one, like ZUNIONSTORE
+	weekly := sortedset.Union([]*sortedset.SortedSet{monday, tuesday}, nil, sortedset.AggregateSum, nil)
+
+	// create a set keeping only the 1000 nodes with highest scores
+	top := sortedset.New(sortedset.WithMaxSize(1000, sortedset.EvictMin))
+
+	// apply several changes which either all succeed or are all undone
+	tx := set.Begin()
+	tx.AddOrUpdate("i", 42, "Hector")
+	tx.Remove("a")
+	if failed {
+	    tx.Rollback() // the set is restored to its state before Begin()
+	} else {
+	    tx.Commit()
+	}
+
+	// be notified of every change, e.g. to push rank changes to players
+	sub := set.Subscribe(func(e sortedset.Event) {
+	    if e.Type == sortedset.EventScoreChanged {
+	        fmt.Println(e.Key, e.OldScore, "->", e.Score)
+	    }
+	})
+	defer sub.Close()
+
+	// rank players within their guild, Value being a Player with a Guild field
+	set.AddIndex("guild", func(value interface{}) (string, bool) {
+	    return value.(Player).Guild, true
+	})
+	set.FindRankIn("guild", "dragons", "a")
+	set.GetByRankRangeIn("guild", "dragons", -1, -10) // top 10 of the guild
 */
 package sortedset
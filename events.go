@@ -0,0 +1,160 @@
+package sortedset
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventType tells how an element of the set changed
+type EventType int
+
+const (
+	EventAdded        EventType = iota + 1 // a new key was added
+	EventScoreChanged                      // the score of a key changed, and possibly its value
+	EventValueChanged                      // the value of a key was set, its score did not change
+	EventRemoved                           // a key was removed, by Remove, PopMin, GetByRankRange with remove...
+	EventEvicted                           // a key was evicted because the set was full or its TTL expired
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAdded:
+		return "added"
+	case EventScoreChanged:
+		return "score changed"
+	case EventValueChanged:
+		return "value changed"
+	case EventRemoved:
+		return "removed"
+	case EventEvicted:
+		return "evicted"
+	}
+	return "unknown"
+}
+
+// Event describes the change of a key of the set. For EventRemoved and
+// EventEvicted, Score and Value are the ones of the element that was removed.
+type Event struct {
+	Type     EventType
+	Key      string
+	Score    float64     // score after the change
+	OldScore float64     // score before the change, unless the key was added
+	Value    interface{} // value after the change
+	OldValue interface{} // value before the change, unless the key was added
+
+	// Node is the node holding Key after the change, nil if the key was
+	// removed. It belongs to the set and must not be used from another
+	// goroutine than the one changing the set.
+	Node *Node
+}
+
+// Subscription is a registration of Subscribe or SubscribeChan
+type Subscription struct {
+	set     *SortedSet
+	mu      sync.Locker // lock of the set, if it is shared by goroutines
+	l       *listener
+	ch      chan Event
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Subscribe register fn to be called with every change of the set, right
+// after the change is made and before the method making it returns.
+//
+// fn must not change the set. Events are also sent when a transaction is
+// rolled back, and when the content of the set is replaced by
+// UnmarshalBinary, UnmarshalJSON or one of the store methods, every old key
+// being removed before every new key is added.
+func (set *SortedSet) Subscribe(fn func(e Event)) *Subscription {
+	s := &Subscription{set: set}
+	s.l = set.listen(fn)
+	return s
+}
+
+// SubscribeChan Create a channel receiving every change of the set, see
+// Subscribe, buffering up to size events. Changing the set never blocks: an
+// event is dropped when the buffer is full, see Subscription.Dropped.
+// The channel is closed by Subscription.Close.
+func (set *SortedSet) SubscribeChan(size int) (<-chan Event, *Subscription) {
+	s := &Subscription{set: set, ch: make(chan Event, size)}
+	s.l = set.listen(s.send)
+	return s.ch, s
+}
+
+func (s *Subscription) send(e Event) {
+	select {
+	case s.ch <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped Get the number of events of a SubscribeChan subscription which were
+// dropped because the channel was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close Stop sending events to the subscription, and close its channel if
+// it was created by SubscribeChan. Closing it again does nothing.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		if s.mu != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+		}
+		s.set.unlisten(s.l)
+		if s.ch != nil {
+			close(s.ch)
+		}
+	})
+}
+
+// previous is the state of a key before a change, kept until the change is
+// notified to listeners
+type previous struct {
+	exists bool
+	score  float64
+	value  interface{}
+}
+
+/* Remember the state of key, held by node (nil if absent), before it changes
+ * so listeners can be told what changed. */
+func (set *SortedSet) remember(key string, node *Node) {
+	if len(set.listeners) == 0 {
+		return
+	}
+	if set.pending == nil {
+		set.pending = make(map[string]previous)
+	}
+	if node == nil {
+		set.pending[key] = previous{}
+	} else {
+		set.pending[key] = previous{exists: true, score: node.score, value: node.Value}
+	}
+}
+
+/* Build the event of a change of key from its remembered previous state. */
+func (set *SortedSet) event(key string, node *Node) Event {
+	old := set.pending[key]
+	delete(set.pending, key)
+	e := Event{Key: key, Node: node, OldScore: old.score, OldValue: old.value}
+	switch {
+	case node == nil:
+		e.Type = EventRemoved
+		if set.evicting {
+			e.Type = EventEvicted
+		}
+		e.Score, e.Value = old.score, old.value
+	case !old.exists:
+		e.Type = EventAdded
+	case sameScore(old.score, node.score):
+		e.Type = EventValueChanged
+	default:
+		e.Type = EventScoreChanged
+	}
+	if node != nil {
+		e.Score, e.Value = node.score, node.Value
+	}
+	return e
+}
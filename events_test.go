@@ -0,0 +1,133 @@
+package sortedset
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* Format events as "type key old->new", to compare them with expectations. */
+func formatEvents(events []Event) string {
+	lines := make([]string, len(events))
+	for i, e := range events {
+		lines[i] = fmt.Sprintf("%s %s %v/%v->%v/%v", e.Type, e.Key, e.OldScore, e.OldValue, e.Score, e.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func checkEvents(t *testing.T, events *[]Event, expected ...string) {
+	t.Helper()
+	if actual := formatEvents(*events); actual != strings.Join(expected, "\n") {
+		t.Errorf("events are\n%s\nexpected\n%s", actual, strings.Join(expected, "\n"))
+	}
+	*events = nil
+}
+
+func TestSubscribe(t *testing.T) {
+	set := New(WithMaxSize(3, EvictMin))
+	var events []Event
+	sub := set.Subscribe(func(e Event) {
+		if e.Node != set.GetByKey(e.Key) {
+			t.Errorf("node of %q is not the one held by the set", e.Key)
+		}
+		events = append(events, e)
+	})
+
+	set.AddOrUpdate("a", 1, "v1")
+	set.AddOrUpdate("a", 1, "v2")
+	set.AddOrUpdate("a", 5, "v3")
+	set.IncrBy("b", 2)
+	checkEvents(t, &events,
+		"added a 0/<nil>->1/v1",
+		"value changed a 1/v1->1/v2",
+		"score changed a 1/v2->5/v3",
+		"added b 0/<nil>->2/<nil>")
+
+	set.AddOrUpdate("c", 3, nil)
+	set.AddOrUpdate("d", 4, nil)
+	set.AddOrUpdate("e", 0, nil) // lower than the minimum of a full set, ignored
+	checkEvents(t, &events,
+		"added c 0/<nil>->3/<nil>",
+		"added d 0/<nil>->4/<nil>",
+		"evicted b 2/<nil>->2/<nil>")
+
+	set.Remove("c")
+	set.PopMax()
+	set.AddOrUpdate("f", 6, nil)
+	set.GetByRankRange(1, -1, true)
+	checkEvents(t, &events,
+		"removed c 3/<nil>->3/<nil>",
+		"removed a 5/v3->5/v3",
+		"added f 0/<nil>->6/<nil>",
+		"removed d 4/<nil>->4/<nil>",
+		"removed f 6/<nil>->6/<nil>")
+
+	sub.Close()
+	sub.Close()
+	set.AddOrUpdate("g", 7, nil)
+	checkEvents(t, &events)
+	if len(set.pending) != 0 {
+		t.Errorf("%d previous states are still kept", len(set.pending))
+	}
+}
+
+func TestSubscribeExpiryAndRollback(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	set := New(WithClock(func() time.Time { return now }))
+	set.AddOrUpdate("a", 1, nil)
+	set.AddOrUpdateWithTTL("b", 2, nil, time.Minute)
+
+	var events []Event
+	set.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+	now = now.Add(time.Hour)
+	set.GetCount()
+	checkEvents(t, &events, "evicted b 2/<nil>->2/<nil>")
+
+	tx := set.Begin()
+	tx.AddOrUpdate("a", 10, "x")
+	tx.AddOrUpdate("c", 3, nil)
+	tx.Rollback()
+	checkEvents(t, &events,
+		"score changed a 1/<nil>->10/x",
+		"added c 0/<nil>->3/<nil>",
+		"removed c 3/<nil>->3/<nil>",
+		"score changed a 10/x->1/<nil>")
+
+	other := New()
+	other.AddOrUpdate("z", 9, nil)
+	data, _ := other.MarshalBinary()
+	set.UnmarshalBinary(data)
+	checkEvents(t, &events, "removed a 1/<nil>->1/<nil>", "added z 0/<nil>->9/<nil>")
+}
+
+func TestSubscribeChan(t *testing.T) {
+	set := NewConcurrent()
+	ch, sub := set.SubscribeChan(2)
+	set.AddOrUpdate("a", 1, nil)
+	set.AddOrUpdate("b", 2, nil)
+	set.AddOrUpdate("c", 3, nil)
+	if sub.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, expected 1", sub.Dropped())
+	}
+	if e := <-ch; e.Type != EventAdded || e.Key != "a" {
+		t.Errorf("first event is %v %q, expected a to be added", e.Type, e.Key)
+	}
+
+	done := make(chan []string)
+	go func() {
+		var keys []string
+		for e := range ch {
+			keys = append(keys, e.Key)
+		}
+		done <- keys
+	}()
+	set.Remove("a")
+	sub.Close()
+	set.Remove("b")
+	if keys := <-done; fmt.Sprint(keys) != "[b a]" {
+		t.Errorf("received %v, expected [b a]", keys)
+	}
+}
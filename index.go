@@ -0,0 +1,159 @@
+package sortedset
+
+// index is a secondary index of the set, see AddIndex
+type index struct {
+	extract func(value interface{}) (group string, ok bool)
+	groups  map[string]*SortedSet // members of every group, ordered like the set, holding the nodes of the set as values
+	groupOf map[string]string     // group of every indexed key
+}
+
+// AddIndex Register a secondary index named name, grouping the elements by
+// the group extract returns for their Value, e.g. the guild of a player.
+// Elements for which extract returns false are not indexed. The elements of
+// every group are kept ordered like the set, so they can be ranked within
+// their group by FindRankIn and GetByRankRangeIn without scanning the set.
+//
+// The index is updated by every change of the set. A Value which is changed
+// in place is not indexed again until its element is updated, e.g. by
+// AddOrUpdate. An index already registered with the same name is replaced.
+//
+// Time complexity of this method is : O(N*log(N))
+func (set *SortedSet) AddIndex(name string, extract func(value interface{}) (group string, ok bool)) {
+	set.evictExpired()
+	idx := &index{extract: extract}
+	idx.build(set)
+	if set.indexes == nil {
+		set.indexes = make(map[string]*index)
+	}
+	set.indexes[name] = idx
+}
+
+// RemoveIndex Unregister the secondary index named name, if any
+func (set *SortedSet) RemoveIndex(name string) {
+	delete(set.indexes, name)
+}
+
+// GetCountIn Get the number of elements in group of the index named name,
+// 0 if there is no such index or group
+//
+// Time complexity of this method is : O(1)
+func (set *SortedSet) GetCountIn(name string, group string) int {
+	set.evictExpired()
+	if members := set.group(name, group); members != nil {
+		return members.GetCount()
+	}
+	return 0
+}
+
+// FindRankIn Find the rank of the node specified by key within its group of
+// the index named name, see FindRank. Rank 1 means the first node of the group.
+//
+// If the index does not exist or the node is not in group, 0 is returned.
+//
+// Time complexity of this method is : O(log(M)) for M elements in the group
+func (set *SortedSet) FindRankIn(name string, group string, key string) int {
+	set.evictExpired()
+	if members := set.group(name, group); members != nil {
+		return members.FindRank(key)
+	}
+	return 0
+}
+
+// GetByRankRangeIn Get the nodes of group of the index named name within
+// specific rank range [start, end] of the group, see GetByRankRange. The
+// nodes with the N highest scores of a group are given by start -1 and end -N.
+//
+// If the index or the group does not exist, nil is returned.
+//
+// Time complexity of this method is : O(log(M)) for M elements in the group
+func (set *SortedSet) GetByRankRangeIn(name string, group string, start int, end int) []*Node {
+	set.evictExpired()
+	members := set.group(name, group)
+	if members == nil {
+		return nil
+	}
+	found := members.GetByRankRange(start, end, false)
+	nodes := make([]*Node, len(found))
+	for i, x := range found {
+		nodes[i] = x.Value.(*Node)
+	}
+	return nodes
+}
+
+/* Members of group in the index named name, nil if there is none. */
+func (set *SortedSet) group(name string, group string) *SortedSet {
+	if idx := set.indexes[name]; idx != nil {
+		return idx.groups[group]
+	}
+	return nil
+}
+
+/* Update every index after key has changed, node being the node now holding
+ * it or nil if it was removed. */
+func (set *SortedSet) reindex(key string, node *Node) {
+	for _, idx := range set.indexes {
+		idx.update(key, node)
+	}
+}
+
+/* Rebuild every index from the content of the set, after it was replaced. */
+func (set *SortedSet) rebuildIndexes() {
+	for _, idx := range set.indexes {
+		idx.build(set)
+	}
+}
+
+/* Index every element of set, forgetting the previous content of the index. */
+func (idx *index) build(set *SortedSet) {
+	idx.groups = make(map[string]*SortedSet)
+	idx.groupOf = make(map[string]string)
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		idx.update(x.key, x)
+	}
+}
+
+func (idx *index) update(key string, node *Node) {
+	var group string
+	var ok bool
+	if node != nil {
+		group, ok = idx.extract(node.Value)
+	}
+	if old, indexed := idx.groupOf[key]; indexed && (!ok || old != group) {
+		members := idx.groups[old]
+		members.Remove(key)
+		if members.GetCount() == 0 {
+			delete(idx.groups, old)
+		}
+		delete(idx.groupOf, key)
+	}
+	if !ok {
+		return
+	}
+	members := idx.groups[group]
+	if members == nil {
+		members = New()
+		idx.groups[group] = members
+	}
+	members.AddOrUpdate(key, node.score, node)
+	idx.groupOf[key] = group
+}
+
+/* Copy of the index for clone, a copy of the set it belongs to. */
+func (idx *index) clone(clone *SortedSet) *index {
+	c := &index{
+		extract: idx.extract,
+		groups:  make(map[string]*SortedSet, len(idx.groups)),
+		groupOf: make(map[string]string, len(idx.groupOf)),
+	}
+	for group, members := range idx.groups {
+		members = members.Clone(nil)
+		for x := members.header.level[0].forward; x != nil; x = x.level[0].forward {
+			x.Value = clone.dict[x.key]
+		}
+		c.groups[group] = members
+	}
+	for key, group := range idx.groupOf {
+		c.groupOf[key] = group
+	}
+	return c
+}
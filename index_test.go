@@ -0,0 +1,137 @@
+package sortedset
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+type member struct {
+	guild string
+}
+
+func byGuild(value interface{}) (string, bool) {
+	m, ok := value.(member)
+	return m.guild, ok && m.guild != ""
+}
+
+/* Check the groups of the index named name against a scan of the set. */
+func checkIndex(t *testing.T, set *SortedSet, name string) {
+	t.Helper()
+	expected := make(map[string][]*Node)
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if group, ok := byGuild(x.Value); ok {
+			expected[group] = append(expected[group], x)
+		}
+	}
+	idx := set.indexes[name]
+	if len(idx.groups) != len(expected) {
+		t.Fatalf("index has %d groups, expected %d", len(idx.groups), len(expected))
+	}
+	for group, nodes := range expected {
+		if count := set.GetCountIn(name, group); count != len(nodes) {
+			t.Fatalf("group %s has %d elements, expected %d", group, count, len(nodes))
+		}
+		found := set.GetByRankRangeIn(name, group, 1, -1)
+		for i, x := range nodes {
+			if found[i] != x {
+				t.Fatalf("node at rank %d of group %s is %v, expected %v", i+1, group, found[i].Key(), x.Key())
+			}
+			if rank := set.FindRankIn(name, group, x.key); rank != i+1 {
+				t.Fatalf("FindRankIn(%s) = %d, expected %d", x.key, rank, i+1)
+			}
+		}
+		checkRanks(t, idx.groups[group])
+	}
+}
+
+func TestIndex(t *testing.T) {
+	set := New()
+	set.AddOrUpdate("alice", 10, member{"red"})
+	set.AddOrUpdate("bob", 30, member{"blue"})
+	set.AddOrUpdate("carol", 20, member{"red"})
+	set.AddOrUpdate("dave", 40, nil)
+	set.AddIndex("guild", byGuild)
+	set.AddOrUpdate("erin", 50, member{"red"})
+
+	if rank := set.FindRankIn("guild", "red", "carol"); rank != 2 {
+		t.Errorf("FindRankIn(carol) = %d, expected 2", rank)
+	}
+	if rank := set.FindRankIn("guild", "red", "bob"); rank != 0 {
+		t.Errorf("FindRankIn of a member of another guild = %d, expected 0", rank)
+	}
+	if rank := set.FindRankIn("unknown", "red", "carol"); rank != 0 {
+		t.Errorf("FindRankIn of an unknown index = %d, expected 0", rank)
+	}
+	top := set.GetByRankRangeIn("guild", "red", -1, -2)
+	if len(top) != 2 || top[0].Key() != "erin" || top[1].Key() != "carol" {
+		t.Errorf("top 2 of red = %v, expected erin and carol", top)
+	}
+	if set.GetByRankRangeIn("guild", "green", 1, -1) != nil {
+		t.Error("an unknown group should have no elements")
+	}
+
+	set.AddOrUpdate("alice", 10, member{"blue"}) // moves to another guild
+	set.IncrBy("bob", -25)
+	set.Remove("erin")
+	if rank := set.FindRankIn("guild", "blue", "bob"); rank != 1 {
+		t.Errorf("FindRankIn(bob) = %d, expected 1", rank)
+	}
+	if count := set.GetCountIn("guild", "red"); count != 1 {
+		t.Errorf("GetCountIn(red) = %d, expected 1", count)
+	}
+	checkIndex(t, set, "guild")
+
+	set.RemoveIndex("guild")
+	if set.GetCountIn("guild", "blue") != 0 {
+		t.Error("a removed index should have no elements")
+	}
+}
+
+func TestIndexRandom(t *testing.T) {
+	set := New(WithMaxSize(300, EvictMin))
+	set.AddIndex("guild", byGuild)
+	r := rand.New(rand.NewSource(1))
+	guilds := []string{"", "a", "b", "c", "d"}
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprint("key", r.Intn(400))
+		switch r.Intn(10) {
+		case 0:
+			set.Remove(key)
+		case 1:
+			set.IncrBy(key, float64(r.Intn(10)))
+		case 2:
+			set.PopMin()
+		case 3:
+			set.GetByRankRange(1, 3, true)
+		case 4:
+			tx := set.Begin()
+			tx.AddOrUpdate(key, float64(r.Intn(100)), member{guilds[r.Intn(len(guilds))]})
+			tx.Remove(fmt.Sprint("key", r.Intn(400)))
+			if r.Intn(2) == 0 {
+				tx.Rollback()
+			} else {
+				tx.Commit()
+			}
+		default:
+			set.AddOrUpdate(key, float64(r.Intn(100)), member{guilds[r.Intn(len(guilds))]})
+		}
+		if i%1000 == 0 {
+			checkIndex(t, set, "guild")
+		}
+	}
+	checkIndex(t, set, "guild")
+
+	// the indexes follow a replaced content and are copied by Clone
+	other := New()
+	for i := 0; i < 100; i++ {
+		other.AddOrUpdate(fmt.Sprint("other", i), float64(i), member{guilds[i%len(guilds)]})
+	}
+	clone := set.Clone(nil)
+	set.UnionStore([]*SortedSet{set, other}, nil, AggregateSum, nil)
+	checkIndex(t, set, "guild")
+	checkIndex(t, clone, "guild")
+	clone.AddOrUpdate("key0", 1000, member{"a"})
+	checkIndex(t, clone, "guild")
+	checkIndex(t, set, "guild")
+}
@@ -0,0 +1,146 @@
+package sortedset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+var (
+	_ json.Marshaler   = (*Node)(nil)
+	_ json.Unmarshaler = (*Node)(nil)
+	_ json.Marshaler   = (*SortedSet)(nil)
+	_ json.Unmarshaler = (*SortedSet)(nil)
+)
+
+// jsonNode is the JSON schema of a node, rank is only written for nodes of a set
+type jsonNode struct {
+	Key   string      `json:"key"`
+	Score jsonScore   `json:"score"`
+	Value interface{} `json:"value"`
+	Rank  int         `json:"rank,omitempty"`
+}
+
+// jsonScore is a score encoded as a JSON number, or as the string "inf" or
+// "-inf" if it is infinite since JSON has no number for them
+type jsonScore float64
+
+func (score jsonScore) MarshalJSON() ([]byte, error) {
+	switch {
+	case math.IsInf(float64(score), 1):
+		return []byte(`"inf"`), nil
+	case math.IsInf(float64(score), -1):
+		return []byte(`"-inf"`), nil
+	}
+	return json.Marshal(float64(score))
+}
+
+func (score *jsonScore) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		switch s {
+		case "inf", "+inf":
+			*score = jsonScore(math.Inf(1))
+		case "-inf":
+			*score = jsonScore(math.Inf(-1))
+		default:
+			return fmt.Errorf("sortedset: invalid score %q", s)
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*float64)(score))
+}
+
+// MarshalJSON implements json.Marshaler, a node is encoded as
+//
+//	{"key": "a", "score": 89, "value": "Kelly"}
+//
+// Infinite scores are encoded as the strings "inf" and "-inf".
+func (node *Node) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonNode{Key: node.key, Score: jsonScore(node.score), Value: node.Value})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The node is not part of any set,
+// it can be added to one with AddOrUpdate(node.Key(), node.Score(), node.Value)
+func (node *Node) UnmarshalJSON(data []byte) error {
+	var decoded jsonNode
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*node = Node{key: decoded.Key, score: float64(decoded.Score), Value: decoded.Value}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, the set is encoded as an array of
+// its nodes in rank order, each of them with its rank
+//
+//	[{"key": "d", "score": -321, "value": "Park", "rank": 1}, ...]
+//
+// Use WriteJSON to stream very large sets instead of building the whole document in memory.
+func (set *SortedSet) MarshalJSON() ([]byte, error) {
+	set.evictExpired()
+	nodes := make([]jsonNode, 0, set.length)
+	rank := 0
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		rank++
+		nodes = append(nodes, jsonNode{Key: x.key, Score: jsonScore(x.score), Value: x.Value, Rank: rank})
+	}
+	return json.Marshal(nodes)
+}
+
+// WriteJSON write the same document as MarshalJSON to w, one node at a time
+func (set *SortedSet) WriteJSON(w io.Writer) error {
+	set.evictExpired()
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	bw.WriteByte('[')
+	rank := 0
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if rank > 0 {
+			bw.WriteByte(',')
+		}
+		rank++
+		// Encode appends a newline, which is valid whitespace between elements
+		if err := enc.Encode(jsonNode{Key: x.key, Score: jsonScore(x.score), Value: x.Value, Rank: rank}); err != nil {
+			return err
+		}
+	}
+	bw.WriteByte(']')
+	return bw.Flush()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The content of the set is
+// replaced by the nodes of the array, which may be in any order; ranks are
+// ignored. Options of the set are kept, and a capped set evicts the elements
+// over its maximum size once its content is replaced. It can be called on the
+// zero value of SortedSet.
+func (set *SortedSet) UnmarshalJSON(data []byte) error {
+	var nodes []jsonNode
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return err
+	}
+
+	// build aside so the set is left untouched on error
+	loaded := *set
+	loaded.reset()
+	loaded.tx = nil
+	loaded.listeners = nil
+	loaded.indexes = nil
+	loaded.maxSize = 0 // capped once the content is replaced
+	for _, node := range nodes {
+		if loaded.dict[node.Key] != nil {
+			return fmt.Errorf("sortedset: duplicate key %q", node.Key)
+		}
+		loaded.put(node.Key, float64(node.Score), node.Value)
+	}
+	loaded.tx = set.tx
+	loaded.listeners = set.listeners
+	loaded.indexes = set.indexes
+	loaded.maxSize = set.maxSize
+	set.replaceWith(&loaded)
+	set.evictOverflow()
+	return nil
+}
@@ -0,0 +1,131 @@
+package sortedset
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestNodeJSON(t *testing.T) {
+	sortedset := New()
+	sortedset.AddOrUpdate("a", 89, "Kelly")
+	sortedset.AddOrUpdate("b", 100, map[string]interface{}{"name": "Staley"})
+
+	data, err := json.Marshal(sortedset.GetByRankRange(1, -1, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"key":"a","score":89,"value":"Kelly"},{"key":"b","score":100,"value":{"name":"Staley"}}]`
+	if string(data) != expected {
+		t.Errorf("json.Marshal() returns %s, expected %s", data, expected)
+	}
+
+	var nodes []*Node
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[0].Key() != "a" || nodes[0].Score() != 89 || nodes[0].Value != "Kelly" {
+		t.Errorf("json.Unmarshal() returns unexpected nodes %v", nodes)
+	}
+}
+
+func TestSortedSetJSON(t *testing.T) {
+	sortedset := New()
+	sortedset.AddOrUpdate("a", 89, "Kelly")
+	sortedset.AddOrUpdate("b", 100, "Staley")
+	sortedset.AddOrUpdate("d", -321, nil)
+
+	data, err := json.Marshal(sortedset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"key":"d","score":-321,"value":null,"rank":1},{"key":"a","score":89,"value":"Kelly","rank":2},{"key":"b","score":100,"value":"Staley","rank":3}]`
+	if string(data) != expected {
+		t.Errorf("json.Marshal() returns %s, expected %s", data, expected)
+	}
+
+	var buf bytes.Buffer
+	if err := sortedset.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, buf.Bytes()); err != nil || compacted.String() != expected {
+		t.Errorf("WriteJSON() writes %s, expected %s", buf.String(), expected)
+	}
+
+	var loaded SortedSet
+	if err := json.Unmarshal([]byte(`[{"key":"b","score":100},{"key":"a","score":89,"value":"Kelly"}]`), &loaded); err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, loaded.GetByRankRange(1, -1, false), []string{"a", "b"})
+	checkRanks(t, &loaded)
+
+	if err := json.Unmarshal([]byte(`[{"key":"a","score":1},{"key":"a","score":2}]`), &loaded); err == nil {
+		t.Error("json.Unmarshal() should reject duplicate keys")
+	}
+	if loaded.GetCount() != 2 {
+		t.Error("a failed json.Unmarshal() should leave the set untouched")
+	}
+}
+
+func TestWriteJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New().WriteJSON(&buf); err != nil || buf.String() != "[]" {
+		t.Errorf("WriteJSON() of an empty set writes %q, %v", buf.String(), err)
+	}
+}
+
+func TestJSONInfiniteScores(t *testing.T) {
+	sortedset := New()
+	sortedset.AddOrUpdate("a", math.Inf(-1), nil)
+	sortedset.AddOrUpdate("b", 1.5, nil)
+	sortedset.AddOrUpdate("c", math.Inf(1), nil)
+
+	data, err := json.Marshal(sortedset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"key":"a","score":"-inf","value":null,"rank":1},{"key":"b","score":1.5,"value":null,"rank":2},{"key":"c","score":"inf","value":null,"rank":3}]`
+	if string(data) != expected {
+		t.Errorf("json.Marshal() returns %s, expected %s", data, expected)
+	}
+	var buf bytes.Buffer
+	if err := sortedset.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded SortedSet
+	if err := json.Unmarshal(buf.Bytes(), &loaded); err != nil {
+		t.Fatal(err)
+	}
+	checkSameNodes(t, &loaded, sortedset)
+	var node Node
+	if err := json.Unmarshal([]byte(`{"key":"c","score":"+inf"}`), &node); err != nil || !math.IsInf(node.Score(), 1) {
+		t.Errorf("json.Unmarshal() of +inf returns %v, %v", node.Score(), err)
+	}
+	if err := json.Unmarshal([]byte(`[{"key":"a","score":"high"}]`), &loaded); err == nil {
+		t.Error("json.Unmarshal() should reject a score which is not a number")
+	}
+}
+
+func TestUnmarshalJSONCapped(t *testing.T) {
+	var sortedset *SortedSet
+	var evicted []string
+	sortedset = New(WithMaxSize(3, EvictMin), WithEvictCallback(func(node *Node) {
+		if sortedset.GetByKey("old") != nil {
+			t.Errorf("%q was evicted before the content of the set was replaced", node.Key())
+		}
+		evicted = append(evicted, node.Key())
+	}))
+	sortedset.AddOrUpdate("old", 0, nil)
+
+	data := `[{"key":"a","score":1},{"key":"b","score":2},{"key":"c","score":3},{"key":"d","score":4}]`
+	if err := json.Unmarshal([]byte(data), sortedset); err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"b", "c", "d"})
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("evicted %v, expected a", evicted)
+	}
+}
@@ -0,0 +1,480 @@
+// Package leaderboard serves named leaderboards over HTTP with JSON payloads,
+// each leaderboard being a SortedSet of players scored by their score.
+//
+// The best player has rank 1: by default the highest score, or the lowest one
+// with WithAscending. Handler implements http.Handler with these endpoints:
+//
+//	GET    /leaderboards/{board}                                  number of players
+//	POST   /leaderboards/{board}/scores                           submit a score
+//	GET    /leaderboards/{board}/scores?min=&max=&offset=&limit=  players within a score range
+//	GET    /leaderboards/{board}/top?offset=&limit=               players by rank
+//	GET    /leaderboards/{board}/players/{key}                    score and rank of a player
+//	DELETE /leaderboards/{board}/players/{key}                    remove a player
+//	GET    /leaderboards/{board}/players/{key}/around?radius=     players ranked around a player
+//
+// A score is submitted as {"key": "alice", "score": 12.5} to set it, or as
+// {"key": "alice", "increment": 2} to add to it, with an optional "value" kept
+// along with the player. Players are returned as
+//
+//	{"key": "alice", "score": 12.5, "value": {...}, "rank": 1}
+//
+// Unknown leaderboards and players are answered with 404 and invalid requests
+// with 400, with a body like {"error": "message"}.
+package leaderboard
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+const (
+	defaultLimit    = 10
+	defaultMaxLimit = 100
+	defaultRadius   = 5
+)
+
+// Option configures a Handler created by New
+type Option func(h *Handler)
+
+// WithBoard serve set as the leaderboard named name. The set must not be used
+// by anything else while it is served.
+func WithBoard(name string, set *sortedset.SortedSet) Option {
+	return func(h *Handler) {
+		h.boards[name] = set
+	}
+}
+
+// WithBoardOptions create the leaderboards, on the first score submitted to
+// them, with the given options
+func WithBoardOptions(options ...sortedset.Option) Option {
+	return func(h *Handler) {
+		h.boardOptions = options
+	}
+}
+
+// WithAscending rank the lowest scores first, like times in a race
+func WithAscending() Option {
+	return func(h *Handler) {
+		h.ascending = true
+	}
+}
+
+// WithMaxLimit set the largest page size a client can ask for, 100 by default
+func WithMaxLimit(limit int) Option {
+	return func(h *Handler) {
+		h.maxLimit = limit
+	}
+}
+
+// Handler is an http.Handler serving named leaderboards. It is safe for
+// concurrent use, requests being served one at a time.
+type Handler struct {
+	mu           sync.Mutex
+	boards       map[string]*sortedset.SortedSet
+	boardOptions []sortedset.Option
+	ascending    bool
+	maxLimit     int
+}
+
+// Entry is the JSON representation of a player of a leaderboard
+type Entry struct {
+	Key   string      `json:"key"`
+	Score float64     `json:"score"`
+	Value interface{} `json:"value,omitempty"`
+	Rank  int         `json:"rank"`
+}
+
+// Page is the JSON representation of a list of players. Total is the number
+// of players of the leaderboard, omitted for score ranges. NextOffset is the
+// offset of the next page, omitted on the last page.
+type Page struct {
+	Entries    []Entry `json:"entries"`
+	Total      *int    `json:"total,omitempty"`
+	Offset     int     `json:"offset"`
+	Limit      int     `json:"limit"`
+	NextOffset *int    `json:"next_offset,omitempty"`
+}
+
+// Submission is the JSON body of a score submission. Exactly one of Score
+// and Increment must be given.
+type Submission struct {
+	Key       string      `json:"key"`
+	Score     *float64    `json:"score,omitempty"`
+	Increment *float64    `json:"increment,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// httpError is answered to the client with its status code
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func badRequest(message string) error {
+	return &httpError{http.StatusBadRequest, message}
+}
+
+func notFound(message string) error {
+	return &httpError{http.StatusNotFound, message}
+}
+
+var errMethodNotAllowed = &httpError{http.StatusMethodNotAllowed, "method not allowed"}
+
+// New Create a new Handler without any leaderboard
+func New(options ...Option) *Handler {
+	h := &Handler{
+		boards:   make(map[string]*sortedset.SortedSet),
+		maxLimit: defaultMaxLimit,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status, body, err := h.route(r)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		var httpErr *httpError
+		if !errors.As(err, &httpErr) {
+			httpErr = &httpError{http.StatusInternalServerError, err.Error()}
+		}
+		if httpErr == errMethodNotAllowed {
+			w.Header().Set("Allow", allowed(r.URL.Path))
+		}
+		status, body = httpErr.status, map[string]string{"error": httpErr.message}
+	}
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+/* Split the path into the name of the board and the rest of its segments. */
+func split(path string) (board string, rest []string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "leaderboards" || segments[1] == "" {
+		return "", nil, false
+	}
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[i] = unescaped
+		}
+	}
+	return segments[1], segments[2:], true
+}
+
+/* Methods allowed on a path, for the Allow header of 405 responses. */
+func allowed(path string) string {
+	_, rest, _ := split(path)
+	switch {
+	case len(rest) == 1 && rest[0] == "scores":
+		return "GET, POST"
+	case len(rest) == 2 && rest[0] == "players":
+		return "GET, DELETE"
+	}
+	return "GET"
+}
+
+func (h *Handler) route(r *http.Request) (int, interface{}, error) {
+	name, rest, ok := split(r.URL.EscapedPath())
+	if !ok {
+		return 0, nil, notFound("not found")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(rest) == 1 && rest[0] == "scores" && r.Method == http.MethodPost {
+		return h.submit(name, r)
+	}
+	board := h.boards[name]
+	if board == nil {
+		return 0, nil, notFound("leaderboard not found")
+	}
+	query := r.URL.Query()
+
+	switch {
+	case len(rest) == 0:
+		if r.Method != http.MethodGet {
+			return 0, nil, errMethodNotAllowed
+		}
+		return http.StatusOK, map[string]interface{}{"name": name, "count": board.GetCount()}, nil
+	case len(rest) == 1 && rest[0] == "scores":
+		if r.Method != http.MethodGet {
+			return 0, nil, errMethodNotAllowed
+		}
+		return h.scoreRange(board, query)
+	case len(rest) == 1 && rest[0] == "top":
+		if r.Method != http.MethodGet {
+			return 0, nil, errMethodNotAllowed
+		}
+		return h.top(board, query)
+	case len(rest) == 2 && rest[0] == "players":
+		switch r.Method {
+		case http.MethodGet:
+			return h.player(board, rest[1])
+		case http.MethodDelete:
+			return h.remove(board, rest[1])
+		}
+		return 0, nil, errMethodNotAllowed
+	case len(rest) == 3 && rest[0] == "players" && rest[2] == "around":
+		if r.Method != http.MethodGet {
+			return 0, nil, errMethodNotAllowed
+		}
+		return h.around(board, rest[1], query)
+	}
+	return 0, nil, notFound("not found")
+}
+
+/* POST /leaderboards/{board}/scores */
+func (h *Handler) submit(name string, r *http.Request) (int, interface{}, error) {
+	var submission Submission
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&submission); err != nil {
+		return 0, nil, badRequest("invalid body: " + err.Error())
+	}
+	if submission.Key == "" {
+		return 0, nil, badRequest("key is required")
+	}
+	if (submission.Score == nil) == (submission.Increment == nil) {
+		return 0, nil, badRequest("exactly one of score and increment is required")
+	}
+
+	board := h.boards[name]
+	if board == nil {
+		board = sortedset.New(h.boardOptions...)
+		h.boards[name] = board
+	}
+	status := http.StatusOK
+	node := board.GetByKey(submission.Key)
+	value := submission.Value
+	if node == nil {
+		status = http.StatusCreated
+	} else if value == nil {
+		value = node.Value
+	}
+
+	score := 0.0
+	if submission.Score != nil {
+		score = *submission.Score
+	} else {
+		score = *submission.Increment
+		if node != nil {
+			score += node.Score()
+		}
+	}
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, nil, badRequest("score is not finite")
+	}
+	board.AddOrUpdate(submission.Key, score, value)
+
+	if node = board.GetByKey(submission.Key); node == nil {
+		// not good enough to enter a board created with sortedset.WithMaxSize
+		return http.StatusOK, Entry{Key: submission.Key, Score: score, Value: value}, nil
+	}
+	return status, entry(node, h.rank(board, node.Key())), nil
+}
+
+/* GET /leaderboards/{board}/players/{key} */
+func (h *Handler) player(board *sortedset.SortedSet, key string) (int, interface{}, error) {
+	node := board.GetByKey(key)
+	if node == nil {
+		return 0, nil, notFound("player not found")
+	}
+	return http.StatusOK, entry(node, h.rank(board, key)), nil
+}
+
+/* DELETE /leaderboards/{board}/players/{key} */
+func (h *Handler) remove(board *sortedset.SortedSet, key string) (int, interface{}, error) {
+	if board.Remove(key) == nil {
+		return 0, nil, notFound("player not found")
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+/* GET /leaderboards/{board}/top?offset=&limit= */
+func (h *Handler) top(board *sortedset.SortedSet, query url.Values) (int, interface{}, error) {
+	offset, limit, err := h.pagination(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	total := board.GetCount()
+	page := Page{Entries: []Entry{}, Total: &total, Offset: offset, Limit: limit}
+	if offset < total {
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		page.Entries = entries(h.byRank(board, offset+1, end), offset+1)
+		if end < total {
+			page.NextOffset = &end
+		}
+	}
+	return http.StatusOK, page, nil
+}
+
+/* GET /leaderboards/{board}/players/{key}/around?radius= */
+func (h *Handler) around(board *sortedset.SortedSet, key string, query url.Values) (int, interface{}, error) {
+	radius, err := intParam(query, "radius", defaultRadius)
+	if err != nil {
+		return 0, nil, err
+	}
+	if radius > h.maxLimit/2 {
+		radius = h.maxLimit / 2
+	}
+	rank := h.rank(board, key)
+	if rank == 0 {
+		return 0, nil, notFound("player not found")
+	}
+
+	total := board.GetCount()
+	start, end := rank-radius, rank+radius
+	if start < 1 {
+		start = 1
+	}
+	if end > total {
+		end = total
+	}
+	return http.StatusOK, Page{
+		Entries: entries(h.byRank(board, start, end), start),
+		Total:   &total,
+		Offset:  start - 1,
+		Limit:   end - start + 1,
+	}, nil
+}
+
+/* GET /leaderboards/{board}/scores?min=&max=&offset=&limit= */
+func (h *Handler) scoreRange(board *sortedset.SortedSet, query url.Values) (int, interface{}, error) {
+	min, err := floatParam(query, "min", math.Inf(-1))
+	if err != nil {
+		return 0, nil, err
+	}
+	max, err := floatParam(query, "max", math.Inf(1))
+	if err != nil {
+		return 0, nil, err
+	}
+	if min > max {
+		return 0, nil, badRequest("min is greater than max")
+	}
+	offset, limit, err := h.pagination(query)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// one more node than asked tells whether there is a next page
+	options := &sortedset.GetByScoreRangeOptions{Limit: offset + limit + 1}
+	var nodes []*sortedset.Node
+	switch {
+	case h.ascending:
+		nodes = board.GetByScoreRange(min, max, options)
+	case min < max:
+		nodes = board.GetByScoreRange(max, min, options)
+	default: // a single score is always searched in ascending order
+		nodes = board.GetByScoreRange(min, max, nil)
+		for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		}
+	}
+
+	page := Page{Entries: []Entry{}, Offset: offset, Limit: limit}
+	if offset < len(nodes) {
+		nodes = nodes[offset:]
+		if len(nodes) > limit {
+			nodes = nodes[:limit]
+			next := offset + limit
+			page.NextOffset = &next
+		}
+		page.Entries = entries(nodes, h.rank(board, nodes[0].Key()))
+	}
+	return http.StatusOK, page, nil
+}
+
+func (h *Handler) pagination(query url.Values) (offset, limit int, err error) {
+	if offset, err = intParam(query, "offset", 0); err != nil {
+		return 0, 0, err
+	}
+	if limit, err = intParam(query, "limit", defaultLimit); err != nil {
+		return 0, 0, err
+	}
+	if limit == 0 || limit > h.maxLimit {
+		return 0, 0, badRequest("limit must be between 1 and " + strconv.Itoa(h.maxLimit))
+	}
+	return offset, limit, nil
+}
+
+func intParam(query url.Values, name string, defaultValue int) (int, error) {
+	s := query.Get(name)
+	if s == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, badRequest(name + " must be a non-negative integer")
+	}
+	return n, nil
+}
+
+func floatParam(query url.Values, name string, defaultValue float64) (float64, error) {
+	s := query.Get(name)
+	if s == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, badRequest(name + " must be a number")
+	}
+	return f, nil
+}
+
+/* Rank of a player in the leaderboard, the best one being 1, or 0 if it is not found. */
+func (h *Handler) rank(board *sortedset.SortedSet, key string) int {
+	rank := board.FindRank(key)
+	if rank == 0 || h.ascending {
+		return rank
+	}
+	return board.GetCount() - rank + 1
+}
+
+/* Players from rank start to end of the leaderboard, the best one being 1. */
+func (h *Handler) byRank(board *sortedset.SortedSet, start, end int) []*sortedset.Node {
+	if h.ascending {
+		return board.GetByRankRange(start, end, false)
+	}
+	return board.GetByRankRange(-start, -end, false)
+}
+
+func entry(node *sortedset.Node, rank int) Entry {
+	return Entry{Key: node.Key(), Score: node.Score(), Value: node.Value, Rank: rank}
+}
+
+/* Entries of consecutive players, the first one having the given rank. */
+func entries(nodes []*sortedset.Node, rank int) []Entry {
+	result := make([]Entry, len(nodes))
+	for i, node := range nodes {
+		result[i] = entry(node, rank+i)
+	}
+	return result
+}
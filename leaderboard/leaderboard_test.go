@@ -0,0 +1,186 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+func request(t *testing.T, h http.Handler, method, target, body string) (int, map[string]interface{}) {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var decoded map[string]interface{}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q", method, target, w.Body.String())
+		}
+	}
+	return w.Code, decoded
+}
+
+/* Keys of the entries of a page. */
+func keys(page map[string]interface{}) []string {
+	keys := []string{}
+	entries, _ := page["entries"].([]interface{})
+	for _, entry := range entries {
+		keys = append(keys, entry.(map[string]interface{})["key"].(string))
+	}
+	return keys
+}
+
+func newBoard(options ...Option) http.Handler {
+	board := sortedset.New()
+	for i := 1; i <= 20; i++ {
+		board.AddOrUpdate(fmt.Sprintf("p%02d", i), float64(i*10), nil)
+	}
+	return New(append([]Option{WithBoard("weekly", board)}, options...)...)
+}
+
+func TestSubmit(t *testing.T) {
+	h := New()
+
+	status, body := request(t, h, "POST", "/leaderboards/daily/scores", `{"key": "alice", "score": 10, "value": {"name": "Alice"}}`)
+	if status != http.StatusCreated || body["rank"] != 1.0 {
+		t.Errorf("first submission = %d %v, expected 201 with rank 1", status, body)
+	}
+	request(t, h, "POST", "/leaderboards/daily/scores", `{"key": "bob", "score": 20}`)
+	status, body = request(t, h, "POST", "/leaderboards/daily/scores", `{"key": "alice", "increment": 15}`)
+	if status != http.StatusOK || body["score"] != 25.0 || body["rank"] != 1.0 {
+		t.Errorf("increment = %d %v, expected 200 with score 25 and rank 1", status, body)
+	}
+
+	status, body = request(t, h, "GET", "/leaderboards/daily/players/alice", "")
+	expected := map[string]interface{}{"key": "alice", "score": 25.0, "rank": 1.0, "value": map[string]interface{}{"name": "Alice"}}
+	if status != http.StatusOK || !reflect.DeepEqual(body, expected) {
+		t.Errorf("get player = %d %v, expected %v", status, body, expected)
+	}
+
+	for _, body := range []string{
+		`{"key": "alice"}`,
+		`{"key": "alice", "score": 1, "increment": 1}`,
+		`{"score": 1}`,
+		`{"key": "alice", "score": "high"}`,
+		`{"key": "alice", "score": 1, "unknown": true}`,
+		`not json`,
+	} {
+		if status, _ := request(t, h, "POST", "/leaderboards/daily/scores", body); status != http.StatusBadRequest {
+			t.Errorf("submission %s = %d, expected 400", body, status)
+		}
+	}
+
+	if status, _ := request(t, h, "DELETE", "/leaderboards/daily/players/alice", ""); status != http.StatusNoContent {
+		t.Errorf("delete = %d, expected 204", status)
+	}
+	if status, _ := request(t, h, "GET", "/leaderboards/daily/players/alice", ""); status != http.StatusNotFound {
+		t.Errorf("get deleted player = %d, expected 404", status)
+	}
+	if _, body := request(t, h, "GET", "/leaderboards/daily", ""); body["count"] != 1.0 {
+		t.Errorf("count = %v, expected 1", body["count"])
+	}
+}
+
+func TestTop(t *testing.T) {
+	h := newBoard()
+
+	status, page := request(t, h, "GET", "/leaderboards/weekly/top?limit=3", "")
+	if status != http.StatusOK || !reflect.DeepEqual(keys(page), []string{"p20", "p19", "p18"}) {
+		t.Errorf("top 3 = %d %v", status, keys(page))
+	}
+	if page["total"] != 20.0 || page["next_offset"] != 3.0 {
+		t.Errorf("total and next offset = %v %v, expected 20 3", page["total"], page["next_offset"])
+	}
+
+	_, page = request(t, h, "GET", "/leaderboards/weekly/top?offset=18&limit=5", "")
+	if !reflect.DeepEqual(keys(page), []string{"p02", "p01"}) || page["next_offset"] != nil {
+		t.Errorf("last page = %v, next offset %v", keys(page), page["next_offset"])
+	}
+	entries := page["entries"].([]interface{})
+	if rank := entries[0].(map[string]interface{})["rank"]; rank != 19.0 {
+		t.Errorf("rank of p02 = %v, expected 19", rank)
+	}
+
+	_, page = request(t, h, "GET", "/leaderboards/weekly/top?offset=30", "")
+	if len(keys(page)) != 0 {
+		t.Errorf("page after the end = %v, expected none", keys(page))
+	}
+
+	ascending := newBoard(WithAscending())
+	_, page = request(t, ascending, "GET", "/leaderboards/weekly/top?limit=2", "")
+	if !reflect.DeepEqual(keys(page), []string{"p01", "p02"}) {
+		t.Errorf("ascending top 2 = %v", keys(page))
+	}
+}
+
+func TestAround(t *testing.T) {
+	h := newBoard()
+
+	_, page := request(t, h, "GET", "/leaderboards/weekly/players/p10/around?radius=2", "")
+	if !reflect.DeepEqual(keys(page), []string{"p12", "p11", "p10", "p09", "p08"}) {
+		t.Errorf("around p10 = %v", keys(page))
+	}
+	_, page = request(t, h, "GET", "/leaderboards/weekly/players/p19/around?radius=2", "")
+	if !reflect.DeepEqual(keys(page), []string{"p20", "p19", "p18", "p17"}) {
+		t.Errorf("around p19 = %v", keys(page))
+	}
+	if status, _ := request(t, h, "GET", "/leaderboards/weekly/players/nobody/around", ""); status != http.StatusNotFound {
+		t.Errorf("around a missing player = %d, expected 404", status)
+	}
+}
+
+func TestScoreRange(t *testing.T) {
+	h := newBoard()
+
+	_, page := request(t, h, "GET", "/leaderboards/weekly/scores?min=50&max=100&limit=4", "")
+	if !reflect.DeepEqual(keys(page), []string{"p10", "p09", "p08", "p07"}) || page["next_offset"] != 4.0 {
+		t.Errorf("scores 50-100 = %v, next offset %v", keys(page), page["next_offset"])
+	}
+	entries := page["entries"].([]interface{})
+	if rank := entries[0].(map[string]interface{})["rank"]; rank != 11.0 {
+		t.Errorf("rank of p10 = %v, expected 11", rank)
+	}
+	_, page = request(t, h, "GET", "/leaderboards/weekly/scores?min=50&max=100&offset=4&limit=4", "")
+	if !reflect.DeepEqual(keys(page), []string{"p06", "p05"}) || page["next_offset"] != nil {
+		t.Errorf("second page = %v, next offset %v", keys(page), page["next_offset"])
+	}
+	_, page = request(t, h, "GET", "/leaderboards/weekly/scores?min=70&max=70", "")
+	if !reflect.DeepEqual(keys(page), []string{"p07"}) {
+		t.Errorf("score 70 = %v", keys(page))
+	}
+	code, page := request(t, h, "GET", "/leaderboards/weekly/scores?max=1", "")
+	if code != http.StatusOK || len(keys(page)) != 0 {
+		t.Errorf("scores below every score = %d %v, expected an empty page", code, page)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	h := newBoard()
+	for _, test := range []struct {
+		method, target string
+		expected       int
+	}{
+		{"GET", "/leaderboards/monthly/top", http.StatusNotFound},
+		{"GET", "/leaderboards/weekly/players/nobody", http.StatusNotFound},
+		{"GET", "/leaderboards/weekly/unknown", http.StatusNotFound},
+		{"GET", "/other", http.StatusNotFound},
+		{"GET", "/leaderboards/weekly/top?limit=0", http.StatusBadRequest},
+		{"GET", "/leaderboards/weekly/top?limit=1000", http.StatusBadRequest},
+		{"GET", "/leaderboards/weekly/top?offset=-1", http.StatusBadRequest},
+		{"GET", "/leaderboards/weekly/scores?min=abc", http.StatusBadRequest},
+		{"GET", "/leaderboards/weekly/scores?min=10&max=5", http.StatusBadRequest},
+		{"PUT", "/leaderboards/weekly/top", http.StatusMethodNotAllowed},
+	} {
+		status, body := request(t, h, test.method, test.target, "")
+		if status != test.expected || body["error"] == nil {
+			t.Errorf("%s %s = %d %v, expected %d with an error", test.method, test.target, status, body, test.expected)
+		}
+	}
+}
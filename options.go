@@ -0,0 +1,71 @@
+package sortedset
+
+import "time"
+
+// Option configures a SortedSet created by New
+type Option func(set *SortedSet)
+
+// EvictPolicy determines which element is evicted when a capped set is full
+type EvictPolicy int
+
+const (
+	EvictMin EvictPolicy = iota // evict the element with minimum score, keeping the highest ones
+	EvictMax                    // evict the element with maximum score, keeping the lowest ones
+)
+
+// WithMaxSize cap the number of elements in the set. Once the set holds size
+// elements, adding a new one evicts the element with minimum or maximum score
+// according to policy. A size <= 0 means unlimited, which is the default.
+func WithMaxSize(size int, policy EvictPolicy) Option {
+	return func(set *SortedSet) {
+		set.maxSize = size
+		set.evictPolicy = policy
+	}
+}
+
+// WithEvictCallback register fn to be called with every element evicted
+// because the set reached the size given to WithMaxSize
+func WithEvictCallback(fn func(node *Node)) Option {
+	return func(set *SortedSet) {
+		set.onEvict = fn
+	}
+}
+
+// WithClock make the set read the current time with now instead of
+// time.Now, e.g. to test elements having a TTL
+func WithClock(now func() time.Time) Option {
+	return func(set *SortedSet) {
+		set.now = now
+	}
+}
+
+/* Whether adding a new key would exceed the maximum size. */
+func (set *SortedSet) isFull() bool {
+	return set.maxSize > 0 && set.length >= set.maxSize
+}
+
+/* Whether a new element would be the one evicted by the policy. */
+func (set *SortedSet) wouldEvict(score float64, key string) bool {
+	if set.evictPolicy == EvictMax {
+		max := set.tail
+		return max != nil && (score > max.score || (sameScore(score, max.score) && key > max.key))
+	}
+	min := set.header.level[0].forward
+	return min != nil && (score < min.score || (sameScore(score, min.score) && key < min.key))
+}
+
+/* Evict elements according to the policy until the size is not exceeded. */
+func (set *SortedSet) evictOverflow() {
+	for set.maxSize > 0 && set.length > set.maxSize {
+		victim := set.header.level[0].forward
+		if set.evictPolicy == EvictMax {
+			victim = set.tail
+		}
+		set.evicting = true
+		set.removeNode(victim)
+		set.evicting = false
+		if set.onEvict != nil {
+			set.onEvict(victim)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package rdb
+
+import "hash/crc64"
+
+// Redis checksums RDB files with CRC-64/Jones (reflected, no initial value and
+// no final XOR). hash/crc64 takes the reversed polynomial and inverts the
+// value on entry and exit, which is undone by crc64Update.
+var jonesTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+func crc64Update(crc uint64, p []byte) uint64 {
+	return ^crc64.Update(^crc, jonesTable, p)
+}
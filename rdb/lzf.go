@@ -0,0 +1,58 @@
+package rdb
+
+import "errors"
+
+var errLZF = errors.New("rdb: invalid LZF compressed string")
+
+// lzfMaxRatio is the largest expansion of LZF, a back reference of 3 bytes
+// copying 264 bytes
+const lzfMaxRatio = 264 / 3
+
+// lzfDecompress decompress data compressed by LZF into a buffer of the
+// expected length, as done by Redis for long strings
+func lzfDecompress(in []byte, length int) ([]byte, error) {
+	if length > len(in)*lzfMaxRatio {
+		return nil, errLZF
+	}
+	out := make([]byte, 0, length)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 1<<5 { // literal run of ctrl+1 bytes
+			n := ctrl + 1
+			if i+n > len(in) || len(out)+n > length {
+				return nil, errLZF
+			}
+			out = append(out, in[i:i+n]...)
+			i += n
+			continue
+		}
+
+		// back reference
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(in) {
+				return nil, errLZF
+			}
+			n += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, errLZF
+		}
+		ref := len(out) - ((ctrl&0x1f)<<8 | int(in[i])) - 1
+		i++
+		n += 2
+		if ref < 0 || len(out)+n > length {
+			return nil, errLZF
+		}
+		for ; n > 0; n-- { // byte by byte, the reference may overlap the output
+			out = append(out, out[ref])
+			ref++
+		}
+	}
+	if len(out) != length {
+		return nil, errLZF
+	}
+	return out, nil
+}
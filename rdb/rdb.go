@@ -0,0 +1,514 @@
+// Package rdb reads and writes sorted sets in the RDB format of Redis, so
+// leaderboards can be migrated between Redis and SortedSet.
+//
+// Read parses an RDB file and returns every sorted set it contains, whatever
+// its encoding: ZSET, ZSET_2, ziplist or listpack. Keys of other types are
+// skipped. Write emits an RDB file which can be loaded back by Redis 4.0 and
+// later.
+//
+//	sets, err := rdb.ReadFile("dump.rdb")
+//	leaderboard := sets["leaderboard"]
+//
+//	err = rdb.WriteFile("dump.rdb", map[string]*sortedset.SortedSet{"leaderboard": leaderboard})
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+// ErrInvalidFile is wrapped by the errors returned when an RDB file is malformed
+var ErrInvalidFile = errors.New("rdb: invalid file")
+
+// ErrChecksum is returned when the checksum at the end of an RDB file does not match its content
+var ErrChecksum = errors.New("rdb: checksum mismatch")
+
+const (
+	typeString           = 0
+	typeList             = 1
+	typeSet              = 2
+	typeZSet             = 3
+	typeHash             = 4
+	typeZSet2            = 5
+	typeModule2          = 7
+	typeHashZipmap       = 9
+	typeListZiplist      = 10
+	typeSetIntset        = 11
+	typeZSetZiplist      = 12
+	typeHashZiplist      = 13
+	typeListQuicklist    = 14
+	typeStreamListpacks  = 15
+	typeHashListpack     = 16
+	typeZSetListpack     = 17
+	typeListQuicklist2   = 18
+	typeStreamListpacks2 = 19
+	typeSetListpack      = 20
+	typeStreamListpacks3 = 21
+	typeHashMetadata     = 24
+	typeHashListpackEx   = 25
+
+	opSlotInfo     = 0xf4
+	opFunction2    = 0xf5
+	opModuleAux    = 0xf7
+	opIdle         = 0xf8
+	opFreq         = 0xf9
+	opAux          = 0xfa
+	opResizeDB     = 0xfb
+	opExpireTimeMs = 0xfc
+	opExpireTime   = 0xfd
+	opSelectDB     = 0xfe
+	opEOF          = 0xff
+
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+
+	moduleOpEOF    = 0
+	moduleOpSInt   = 1
+	moduleOpUInt   = 2
+	moduleOpFloat  = 3
+	moduleOpDouble = 4
+	moduleOpString = 5
+
+	writeVersion = 8
+)
+
+// ReadFile read the RDB file at path, see Read
+func ReadFile(path string, options ...sortedset.Option) (map[string]*sortedset.SortedSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sets := make(map[string]*sortedset.SortedSet)
+	err = Read(file, func(db int, key string, set *sortedset.SortedSet) error {
+		sets[key] = set
+		return nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// Read parse an RDB stream and call fn with every sorted set it contains,
+// created with the given options. Members are added with a nil Value. Keys of
+// other types are skipped, as well as expiry times.
+//
+// Parsing stops at the first error returned by fn. The checksum at the end of
+// the file is verified unless it was disabled when the file was saved.
+func Read(r io.Reader, fn func(db int, key string, set *sortedset.SortedSet) error, options ...sortedset.Option) error {
+	d := &decoder{r: bufio.NewReader(r)}
+
+	header := d.bytes(9)
+	if d.err != nil || string(header[:5]) != "REDIS" {
+		return fmt.Errorf("%w: not an RDB file", ErrInvalidFile)
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil {
+		return fmt.Errorf("%w: bad version %q", ErrInvalidFile, header[5:])
+	}
+
+	db := 0
+	for {
+		op := d.byte()
+		if d.err != nil {
+			return d.fail()
+		}
+		switch op {
+		case opEOF:
+			return d.checksum(version)
+		case opSelectDB:
+			db = int(d.length())
+		case opResizeDB:
+			d.length()
+			d.length()
+		case opAux:
+			d.string()
+			d.string()
+		case opExpireTime:
+			d.bytes(4)
+		case opExpireTimeMs:
+			d.bytes(8)
+		case opIdle:
+			d.length()
+		case opFreq:
+			d.byte()
+		case opModuleAux:
+			d.length() // module id
+			d.length() // when opcode
+			d.length() // when
+			d.skipModuleValue()
+		case opFunction2:
+			d.string()
+		case opSlotInfo:
+			d.length()
+			d.length()
+			d.length()
+		default:
+			key := d.string()
+			pairs, ok := d.object(op)
+			if d.err != nil {
+				return d.fail()
+			}
+			if !ok {
+				continue
+			}
+			set := sortedset.New(options...)
+			set.AddOrUpdateBatch(pairs)
+			if err := fn(db, string(key), set); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// decoder reads the primitives of the RDB format, remembering the first
+// error and the checksum of the bytes read so far
+// maxPrealloc is the largest buffer allocated before reading its content
+const maxPrealloc = 64 << 10
+
+type decoder struct {
+	r   *bufio.Reader
+	crc uint64
+	err error
+}
+
+func (d *decoder) fail() error {
+	if d.err == io.EOF || d.err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end of file", ErrInvalidFile)
+	}
+	return d.err
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrInvalidFile, fmt.Sprintf(format, args...))
+	}
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > math.MaxInt32 {
+		d.errorf("length %d is too large", n)
+		return nil
+	}
+	if n <= maxPrealloc {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			d.err = err
+			return nil
+		}
+		d.crc = crc64Update(d.crc, b)
+		return b
+	}
+
+	// the length comes from the file, the buffer only grows with the bytes read
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		d.err = err
+		return nil
+	}
+	b := buf.Bytes()
+	d.crc = crc64Update(d.crc, b)
+	return b
+}
+
+func (d *decoder) byte() byte {
+	b := d.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+/* Read a length, or the encoding of a special string when encoded is true. */
+func (d *decoder) lengthOrEncoding() (length uint64, encoded bool) {
+	first := d.byte()
+	switch first >> 6 {
+	case 0:
+		return uint64(first & 0x3f), false
+	case 1:
+		return uint64(first&0x3f)<<8 | uint64(d.byte()), false
+	case 2:
+		switch first {
+		case 0x80:
+			b := d.bytes(4)
+			if b == nil {
+				return 0, false
+			}
+			return uint64(binary.BigEndian.Uint32(b)), false
+		case 0x81:
+			b := d.bytes(8)
+			if b == nil {
+				return 0, false
+			}
+			return binary.BigEndian.Uint64(b), false
+		}
+		d.errorf("unknown length encoding %#x", first)
+		return 0, false
+	default:
+		return uint64(first & 0x3f), true
+	}
+}
+
+func (d *decoder) length() uint64 {
+	length, encoded := d.lengthOrEncoding()
+	if encoded {
+		d.errorf("unexpected string encoding")
+	}
+	return length
+}
+
+func (d *decoder) string() []byte {
+	length, encoded := d.lengthOrEncoding()
+	if !encoded {
+		return d.bytes(length)
+	}
+	switch length {
+	case encInt8:
+		return []byte(strconv.Itoa(int(int8(d.byte()))))
+	case encInt16:
+		b := d.bytes(2)
+		if b == nil {
+			return nil
+		}
+		return []byte(strconv.Itoa(int(int16(binary.LittleEndian.Uint16(b)))))
+	case encInt32:
+		b := d.bytes(4)
+		if b == nil {
+			return nil
+		}
+		return []byte(strconv.Itoa(int(int32(binary.LittleEndian.Uint32(b)))))
+	case encLZF:
+		compressed := d.length()
+		uncompressed := d.length()
+		data := d.bytes(compressed)
+		if d.err != nil {
+			return nil
+		}
+		if uncompressed > math.MaxInt32 {
+			d.errorf("length %d is too large", uncompressed)
+			return nil
+		}
+		out, err := lzfDecompress(data, int(uncompressed))
+		if err != nil {
+			d.err = err
+		}
+		return out
+	}
+	d.errorf("unknown string encoding %d", length)
+	return nil
+}
+
+/* Read the score of a ZSET, stored as a string prefixed by its length. */
+func (d *decoder) doubleString() float64 {
+	switch length := d.byte(); length {
+	case 253:
+		return math.NaN()
+	case 254:
+		return math.Inf(1)
+	case 255:
+		return math.Inf(-1)
+	default:
+		s := d.bytes(uint64(length))
+		if d.err != nil {
+			return 0
+		}
+		score, err := strconv.ParseFloat(string(s), 64)
+		if err != nil {
+			d.errorf("bad score %q", s)
+		}
+		return score
+	}
+}
+
+func (d *decoder) binaryDouble() float64 {
+	b := d.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+/* Read the value of a key, returning the members of sorted sets and false
+ * for values of other types, which are skipped. */
+func (d *decoder) object(objectType byte) ([]sortedset.Entry, bool) {
+	switch objectType {
+	case typeZSet, typeZSet2:
+		count := d.length()
+		var pairs []sortedset.Entry
+		for i := uint64(0); i < count && d.err == nil; i++ {
+			member := d.string()
+			var score float64
+			if objectType == typeZSet {
+				score = d.doubleString()
+			} else {
+				score = d.binaryDouble()
+			}
+			pairs = append(pairs, sortedset.Entry{Key: string(member), Score: score})
+		}
+		return pairs, true
+	case typeZSetZiplist:
+		blob := d.string()
+		if d.err != nil {
+			return nil, false
+		}
+		elements, err := ziplistElements(blob)
+		return d.pairs(elements, err), true
+	case typeZSetListpack:
+		blob := d.string()
+		if d.err != nil {
+			return nil, false
+		}
+		elements, err := listpackElements(blob)
+		return d.pairs(elements, err), true
+
+	case typeString, typeHashZipmap, typeListZiplist, typeSetIntset, typeHashZiplist,
+		typeHashListpack, typeSetListpack:
+		d.string()
+	case typeList, typeSet, typeListQuicklist:
+		for count := d.length(); count > 0 && d.err == nil; count-- {
+			d.string()
+		}
+	case typeHash:
+		for count := d.length(); count > 0 && d.err == nil; count-- {
+			d.string()
+			d.string()
+		}
+	case typeListQuicklist2:
+		for count := d.length(); count > 0 && d.err == nil; count-- {
+			d.length() // container
+			d.string()
+		}
+	case typeHashMetadata:
+		d.bytes(8) // minimum expiry time
+		for count := d.length(); count > 0 && d.err == nil; count-- {
+			d.length() // expiry time of the field
+			d.string()
+			d.string()
+		}
+	case typeHashListpackEx:
+		d.bytes(8) // minimum expiry time
+		d.string()
+	case typeStreamListpacks, typeStreamListpacks2, typeStreamListpacks3:
+		d.skipStream(objectType)
+	case typeModule2:
+		d.length() // module id
+		d.skipModuleValue()
+	default:
+		d.errorf("unsupported object type %d", objectType)
+	}
+	return nil, false
+}
+
+/* Pair up the elements of a ziplist or listpack, alternating member and score. */
+func (d *decoder) pairs(elements []string, err error) []sortedset.Entry {
+	if err == nil && len(elements)%2 != 0 {
+		err = errors.New("odd number of elements")
+	}
+	if err != nil {
+		d.errorf("%v", err)
+		return nil
+	}
+	pairs := make([]sortedset.Entry, 0, len(elements)/2)
+	for i := 0; i < len(elements); i += 2 {
+		score, err := strconv.ParseFloat(elements[i+1], 64)
+		if err != nil {
+			d.errorf("bad score %q", elements[i+1])
+			return nil
+		}
+		pairs = append(pairs, sortedset.Entry{Key: elements[i], Score: score})
+	}
+	return pairs
+}
+
+func (d *decoder) skipStream(objectType byte) {
+	for count := d.length(); count > 0 && d.err == nil; count-- {
+		d.string() // master entry ID
+		d.string() // listpack
+	}
+	d.length() // number of elements
+	d.length() // last ID
+	d.length()
+	if objectType >= typeStreamListpacks2 {
+		d.length() // first ID
+		d.length()
+		d.length() // max deleted entry ID
+		d.length()
+		d.length() // entries added
+	}
+	for groups := d.length(); groups > 0 && d.err == nil; groups-- {
+		d.string() // name
+		d.length() // last ID
+		d.length()
+		if objectType >= typeStreamListpacks2 {
+			d.length() // entries read
+		}
+		for pending := d.length(); pending > 0 && d.err == nil; pending-- {
+			d.bytes(16) // ID
+			d.bytes(8)  // delivery time
+			d.length()  // delivery count
+		}
+		for consumers := d.length(); consumers > 0 && d.err == nil; consumers-- {
+			d.string() // name
+			d.bytes(8) // seen time
+			if objectType >= typeStreamListpacks3 {
+				d.bytes(8) // active time
+			}
+			for pending := d.length(); pending > 0 && d.err == nil; pending-- {
+				d.bytes(16) // ID
+			}
+		}
+	}
+}
+
+/* Skip a value serialized by a module, made of typed fields up to an EOF opcode. */
+func (d *decoder) skipModuleValue() {
+	for d.err == nil {
+		switch op := d.length(); op {
+		case moduleOpEOF:
+			return
+		case moduleOpSInt, moduleOpUInt:
+			d.length()
+		case moduleOpFloat:
+			d.bytes(4)
+		case moduleOpDouble:
+			d.bytes(8)
+		case moduleOpString:
+			d.string()
+		default:
+			d.errorf("unknown module opcode %d", op)
+		}
+	}
+}
+
+/* Verify the checksum following the EOF opcode, RDB version 5 and later. */
+func (d *decoder) checksum(version int) error {
+	if version < 5 {
+		return nil
+	}
+	expected := d.crc
+	b := d.bytes(8)
+	if d.err != nil {
+		return d.fail()
+	}
+	checksum := binary.LittleEndian.Uint64(b)
+	if checksum != 0 && checksum != expected { // 0 means checksums were disabled
+		return ErrChecksum
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+/* Build an RDB file from its body, adding the header, EOF and checksum. */
+func rdbFile(version int, body ...[]byte) []byte {
+	data := []byte(fmt.Sprintf("REDIS%04d", version))
+	for _, b := range body {
+		data = append(data, b...)
+	}
+	data = append(data, opEOF)
+	return binary.LittleEndian.AppendUint64(data, crc64Update(0, data))
+}
+
+func readAll(t *testing.T, data []byte) map[string]*sortedset.SortedSet {
+	t.Helper()
+	sets := make(map[string]*sortedset.SortedSet)
+	err := Read(bytes.NewReader(data), func(db int, key string, set *sortedset.SortedSet) error {
+		sets[key] = set
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sets
+}
+
+func checkScores(t *testing.T, set *sortedset.SortedSet, expected map[string]float64) {
+	t.Helper()
+	if set == nil {
+		t.Fatal("sorted set not found")
+	}
+	if set.GetCount() != len(expected) {
+		t.Errorf("GetCount() = %d, expected %d", set.GetCount(), len(expected))
+	}
+	for key, score := range expected {
+		node := set.GetByKey(key)
+		if node == nil {
+			t.Errorf("member %q not found", key)
+		} else if node.Score() != score && !(math.IsNaN(score) && math.IsNaN(node.Score())) {
+			t.Errorf("score of %q = %v, expected %v", key, node.Score(), score)
+		}
+	}
+}
+
+func TestCRC64(t *testing.T) {
+	// test vector of the Redis implementation
+	if crc := crc64Update(0, []byte("123456789")); crc != 0xe9c6d914c4b8d9ca {
+		t.Errorf("crc64(123456789) = %#x", crc)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	leaderboard := sortedset.New()
+	for i := 0; i < 1000; i++ {
+		leaderboard.AddOrUpdate(fmt.Sprint("player", i), float64(i%100)/4, nil)
+	}
+	empty := sortedset.New()
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := WriteFile(path, map[string]*sortedset.SortedSet{"leaderboard": leaderboard, "empty": empty}); err != nil {
+		t.Fatal(err)
+	}
+
+	sets, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("ReadFile() returned %d sets, expected 2", len(sets))
+	}
+	checkScores(t, sets["empty"], map[string]float64{})
+	loaded := sets["leaderboard"]
+	if loaded.GetCount() != leaderboard.GetCount() {
+		t.Fatalf("GetCount() = %d, expected %d", loaded.GetCount(), leaderboard.GetCount())
+	}
+	for x, y := leaderboard.PeekMin(), loaded.PeekMin(); x != nil; x, y = x.Next(), y.Next() {
+		if x.Key() != y.Key() || x.Score() != y.Score() {
+			t.Fatalf("loaded %s %v, expected %s %v", y.Key(), y.Score(), x.Key(), x.Score())
+		}
+	}
+}
+
+func TestReadZSet(t *testing.T) {
+	// scores stored as strings, with the special lengths of infinities and NaN
+	data := rdbFile(3,
+		[]byte{opSelectDB, 0x00},
+		[]byte{typeZSet, 0x01, 'z', 0x04},
+		[]byte{0x01, 'a', 0x03, '1', '.', '5'},
+		[]byte{0x01, 'b', 254},
+		[]byte{0x01, 'c', 255},
+		[]byte{0x01, 'd', 253},
+	)
+	checkScores(t, readAll(t, data)["z"], map[string]float64{
+		"a": 1.5, "b": math.Inf(1), "c": math.Inf(-1), "d": math.NaN(),
+	})
+}
+
+func TestReadZiplist(t *testing.T) {
+	ziplist := []byte{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // size, offset of the tail and count, not used
+		0x00, 0x01, 'a', // "a"
+		0x03, 0xf2, // 1, immediate integer
+		0x02, 0x01, 'b', // "b"
+		0x03, 0x03, '2', '.', '5', // "2.5"
+		0x05, 0x01, 'c', // "c"
+		0x03, 0xc0, 0x2c, 0x01, // 300, int16
+		0x04, 0x01, 'd', // "d"
+		0x03, 0xfe, 0xfb, // -5, int8
+		0x03, 0x01, 'e', // "e"
+		0x03, 0xf0, 0x00, 0x00, 0x80, // -8388608, int24
+		0xff,
+	}
+	data := rdbFile(9,
+		[]byte{opSelectDB, 0x00},
+		append([]byte{typeZSetZiplist, 0x01, 'z', byte(len(ziplist))}, ziplist...),
+	)
+	checkScores(t, readAll(t, data)["z"], map[string]float64{
+		"a": 1, "b": 2.5, "c": 300, "d": -5, "e": -8388608,
+	})
+}
+
+func TestReadListpack(t *testing.T) {
+	listpack := []byte{
+		0, 0, 0, 0, 0, 0, // size and count, not used
+		0x81, 'a', 0x02, // "a"
+		0x01, 0x01, // 1, 7 bit integer
+		0x81, 'b', 0x02, // "b"
+		0x83, '2', '.', '5', 0x04, // "2.5"
+		0x81, 'c', 0x02, // "c"
+		0xde, 0xd4, 0x02, // -300, 13 bit integer
+		0x81, 'd', 0x02, // "d"
+		0xf2, 0xa0, 0x86, 0x01, 0x04, // 100000, int24
+		0xff,
+	}
+	data := rdbFile(11,
+		[]byte{opAux, 0x03, 'f', 'o', 'o', 0x03, 'b', 'a', 'r'},
+		[]byte{opSelectDB, 0x02, opResizeDB, 0x02, 0x01},
+		[]byte{opExpireTimeMs, 0, 0, 0, 0, 0, 0, 0, 0},
+		append([]byte{typeZSetListpack, 0x01, 'z', byte(len(listpack))}, listpack...),
+	)
+
+	var db int
+	err := Read(bytes.NewReader(data), func(d int, key string, set *sortedset.SortedSet) error {
+		db = d
+		checkScores(t, set, map[string]float64{"a": 1, "b": 2.5, "c": -300, "d": 100000})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db != 2 {
+		t.Errorf("db = %d, expected 2", db)
+	}
+}
+
+func TestReadEncodedStrings(t *testing.T) {
+	data := rdbFile(9,
+		[]byte{opSelectDB, 0x00},
+		// other types are skipped
+		[]byte{typeString, 0x01, 's', 0x05, 'h', 'e', 'l', 'l', 'o'},
+		[]byte{typeList, 0x01, 'l', 0x02, 0x01, 'x', 0x01, 'y'},
+		[]byte{typeZSet2, 0x01, 'z', 0x03},
+		[]byte{0xc0, 0x7b, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f},                                    // "123" as int8, 1
+		[]byte{0xc1, 0x39, 0x30, 0, 0, 0, 0, 0, 0, 0, 0x40},                                 // "12345" as int16, 2
+		[]byte{0xc3, 0x05, 0x0a, 0x00, 'a', 0xe0, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0x08, 0x40}, // "aaaaaaaaaa" compressed by LZF, 3
+	)
+	sets := readAll(t, data)
+	if len(sets) != 1 {
+		t.Errorf("Read() returned %d sets, expected 1", len(sets))
+	}
+	checkScores(t, sets["z"], map[string]float64{"123": 1, "12345": 2, "aaaaaaaaaa": 3})
+}
+
+func TestReadErrors(t *testing.T) {
+	valid := rdbFile(9, []byte{opSelectDB, 0x00, typeZSet2, 0x01, 'z', 0x01, 0x01, 'a', 0, 0, 0, 0, 0, 0, 0xf0, 0x3f})
+
+	noChecksum := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint64(noChecksum[len(noChecksum)-8:], 0)
+	if sets := readAll(t, noChecksum); sets["z"] == nil {
+		t.Error("checksum should not be verified when disabled")
+	}
+
+	corrupted := append([]byte{}, valid...)
+	corrupted[len(corrupted)-12] ^= 1
+	for _, test := range []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"checksum", corrupted, ErrChecksum},
+		{"truncated", valid[:len(valid)-12], ErrInvalidFile},
+		{"not an RDB file", []byte("hello world"), ErrInvalidFile},
+		{"unknown type", rdbFile(9, []byte{0x64, 0x01, 'k'}), ErrInvalidFile},
+		{"bad ziplist", rdbFile(9, []byte{typeZSetZiplist, 0x01, 'z', 0x02, 0x00, 0xff}), ErrInvalidFile},
+		{"huge string", rdbFile(9, []byte{typeZSet2, 0x80, 0x7f, 0xff, 0xff, 0xff, 'z'}), ErrInvalidFile},
+		{"huge LZF string", rdbFile(9, []byte{typeZSet2, 0xc0 | encLZF, 0x01, 0x80, 0x7f, 0xff, 0xff, 0xff, 0x00, 'z'}), errLZF},
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		err := Read(bytes.NewReader(test.data), func(int, string, *sortedset.SortedSet) error { return nil })
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: Read() = %v, expected %v", test.name, err, test.expected)
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("%s: Read() allocated %d bytes", test.name, allocated)
+		}
+	}
+
+	stop := errors.New("stop")
+	err := Read(bytes.NewReader(valid), func(int, string, *sortedset.SortedSet) error { return stop })
+	if err != stop {
+		t.Errorf("Read() = %v, expected the error of the callback", err)
+	}
+}
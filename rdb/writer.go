@@ -0,0 +1,138 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+// WriteFile write the sorted sets to a new RDB file at path, see Write. The
+// file is written to a temporary file renamed over path once complete.
+func WriteFile(path string, sets map[string]*sortedset.SortedSet) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	err = Write(tmp, sets)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Write write the sorted sets as an RDB file to w, each one under the key it
+// is mapped to in database 0. Values of the nodes are not written since Redis
+// has no place for them.
+//
+// The file uses RDB version 8 and the ZSET_2 encoding, so it can be loaded by
+// Redis 4.0 and later. Keys are written in lexicographic order.
+func Write(w io.Writer, sets map[string]*sortedset.SortedSet) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.write([]byte(fmt.Sprintf("REDIS%04d", writeVersion)))
+	e.aux("redis-bits", "64")
+
+	keys := make([]string, 0, len(sets))
+	for key := range sets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	e.write([]byte{opSelectDB})
+	e.length(0)
+	e.write([]byte{opResizeDB})
+	e.length(uint64(len(keys)))
+	e.length(0)
+	for _, key := range keys {
+		set := sets[key]
+		e.write([]byte{typeZSet2})
+		e.string(key)
+		e.length(uint64(set.GetCount()))
+		// like Redis, from the highest score so the set is rebuilt by prepending
+		for x := set.PeekMax(); x != nil; x = x.Previous() {
+			e.string(x.Key())
+			e.double(x.Score())
+		}
+	}
+	e.write([]byte{opEOF})
+
+	var checksum [8]byte
+	binary.LittleEndian.PutUint64(checksum[:], e.crc)
+	e.write(checksum[:])
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// encoder writes the primitives of the RDB format, remembering the first
+// error and the checksum of the bytes written so far
+type encoder struct {
+	w   *bufio.Writer
+	crc uint64
+	err error
+}
+
+func (e *encoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	e.crc = crc64Update(e.crc, b)
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) length(length uint64) {
+	var buf [9]byte
+	switch {
+	case length < 1<<6:
+		buf[0] = byte(length)
+		e.write(buf[:1])
+	case length < 1<<14:
+		buf[0], buf[1] = byte(length>>8)|0x40, byte(length)
+		e.write(buf[:2])
+	case length <= math.MaxUint32:
+		buf[0] = 0x80
+		binary.BigEndian.PutUint32(buf[1:], uint32(length))
+		e.write(buf[:5])
+	default:
+		buf[0] = 0x81
+		binary.BigEndian.PutUint64(buf[1:], length)
+		e.write(buf[:9])
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.length(uint64(len(s)))
+	e.write([]byte(s))
+}
+
+func (e *encoder) double(f float64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+	e.write(buf[:])
+}
+
+func (e *encoder) aux(key, value string) {
+	e.write([]byte{opAux})
+	e.string(key)
+	e.string(value)
+}
@@ -0,0 +1,168 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+var (
+	errZiplist  = errors.New("invalid ziplist")
+	errListpack = errors.New("invalid listpack")
+)
+
+// ziplistElements decode the elements of a ziplist, the compact encoding
+// of small sorted sets up to Redis 6.2, integers being formatted as strings
+func ziplistElements(data []byte) ([]string, error) {
+	if len(data) < 11 || data[len(data)-1] != 0xff {
+		return nil, errZiplist
+	}
+	var elements []string
+	for i := 10; data[i] != 0xff; {
+		// length of the previous entry, only used to iterate backwards
+		if data[i] == 0xfe {
+			i += 5
+		} else {
+			i++
+		}
+		if i >= len(data)-1 {
+			return nil, errZiplist
+		}
+
+		enc := data[i]
+		var length int
+		switch {
+		case enc>>6 == 0:
+			length, i = int(enc&0x3f), i+1
+		case enc>>6 == 1:
+			if i+2 > len(data) {
+				return nil, errZiplist
+			}
+			length, i = int(enc&0x3f)<<8|int(data[i+1]), i+2
+		case enc == 0x80:
+			if i+5 > len(data) {
+				return nil, errZiplist
+			}
+			length, i = int(binary.BigEndian.Uint32(data[i+1:])), i+5
+		default:
+			var size int
+			switch enc {
+			case 0xc0:
+				size = 2
+			case 0xd0:
+				size = 4
+			case 0xe0:
+				size = 8
+			case 0xf0:
+				size = 3
+			case 0xfe:
+				size = 1
+			default:
+				if enc < 0xf1 || enc > 0xfd {
+					return nil, errZiplist
+				}
+				elements = append(elements, strconv.Itoa(int(enc&0x0f)-1))
+				i++
+				continue
+			}
+			if i+1+size > len(data)-1 {
+				return nil, errZiplist
+			}
+			elements = append(elements, strconv.FormatInt(littleEndianInt(data[i+1:i+1+size]), 10))
+			i += 1 + size
+			continue
+		}
+		if length < 0 || i+length > len(data)-1 {
+			return nil, errZiplist
+		}
+		elements = append(elements, string(data[i:i+length]))
+		i += length
+	}
+	return elements, nil
+}
+
+// listpackElements decode the elements of a listpack, the compact encoding
+// of small sorted sets since Redis 7.0, integers being formatted as strings
+func listpackElements(data []byte) ([]string, error) {
+	if len(data) < 7 || data[len(data)-1] != 0xff {
+		return nil, errListpack
+	}
+	var elements []string
+	for i := 6; data[i] != 0xff; {
+		enc := data[i]
+		var header, length int
+		var value string
+		isString := false
+		switch {
+		case enc>>7 == 0: // 7 bit unsigned integer
+			header, value = 1, strconv.Itoa(int(enc))
+		case enc>>6 == 2: // string of up to 63 bytes
+			header, length, isString = 1, int(enc&0x3f), true
+		case enc>>5 == 6: // 13 bit signed integer
+			if i+2 > len(data) {
+				return nil, errListpack
+			}
+			v := int(enc&0x1f)<<8 | int(data[i+1])
+			if v >= 1<<12 {
+				v -= 1 << 13
+			}
+			header, value = 2, strconv.Itoa(v)
+		case enc>>4 == 14: // string of up to 4095 bytes
+			if i+2 > len(data) {
+				return nil, errListpack
+			}
+			header, length, isString = 2, int(enc&0x0f)<<8|int(data[i+1]), true
+		case enc == 0xf0:
+			if i+5 > len(data) {
+				return nil, errListpack
+			}
+			header, length, isString = 5, int(binary.LittleEndian.Uint32(data[i+1:])), true
+		case enc >= 0xf1 && enc <= 0xf4:
+			size := [...]int{2, 3, 4, 8}[enc-0xf1]
+			if i+1+size > len(data) {
+				return nil, errListpack
+			}
+			header, value = 1+size, strconv.FormatInt(littleEndianInt(data[i+1:i+1+size]), 10)
+		default:
+			return nil, errListpack
+		}
+		if length < 0 || i+header+length > len(data)-1 {
+			return nil, errListpack
+		}
+		if isString {
+			value = string(data[i+header : i+header+length])
+		}
+		elements = append(elements, value)
+		i += header + length + backlenSize(header+length)
+		if i >= len(data) {
+			return nil, errListpack
+		}
+	}
+	return elements, nil
+}
+
+/* Size of the length of an entry, stored after it to iterate backwards. */
+func backlenSize(length int) int {
+	switch {
+	case length <= 127:
+		return 1
+	case length < 16383:
+		return 2
+	case length < 2097151:
+		return 3
+	case length < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+/* Decode a little endian two's complement integer of up to 8 bytes. */
+func littleEndianInt(b []byte) int64 {
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	shift := uint(64 - 8*len(b))
+	return int64(v<<shift) >> shift
+}
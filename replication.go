@@ -0,0 +1,288 @@
+package sortedset
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrResyncRequired is returned by Leader.Serve when the changes following
+	// the offset of a follower are no longer in the backlog, or were never
+	// made. The follower must be resynced from Leader.Snapshot.
+	ErrResyncRequired = errors.New("sortedset: replication offset not in backlog, resync required")
+
+	// ErrReplicationGap is returned by Follower.Apply when a change of the
+	// stream does not directly follow the last applied one
+	ErrReplicationGap = errors.New("sortedset: gap in replication stream")
+
+	// ErrLeaderClosed is returned by Leader.Serve once the leader is closed
+	ErrLeaderClosed = errors.New("sortedset: replication leader closed")
+)
+
+// Leader numbers every change of a SortedSet and streams them to followers,
+// see NewLeader. Changes are numbered from 1, and the offset of a follower is
+// the number of the last change it applied.
+//
+// The stream is a sequence of records made of the length and CRC32 of the
+// payload, both uint32, then the payload: the number of the change as a
+// uint64, then the operation encoded like in the write-ahead log. TTLs are
+// not replicated: followers ignore the deadline of the operation, and expired
+// elements are removed from followers when the leader evicts them.
+// Followers therefore never evict by themselves, which lets View read them
+// concurrently.
+type Leader struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // signaled when a change is recorded or the leader is closed
+	set     *SortedSet
+	codec   ValueCodec
+	l       *listener
+	seq     uint64   // number of the last change
+	backlog [][]byte // records of the last changes, the last one being seq
+	size    int      // maximum number of records in the backlog
+	err     error    // first error while encoding a change
+	closed  bool
+}
+
+// NewLeader Start numbering the changes of set, keeping the records of the
+// last backlog changes so followers lagging behind by less than backlog
+// changes can catch up without a resync.
+//
+// Like the set itself, NewLeader, Snapshot and Close must not be called
+// concurrently with changes of the set. Serve and Seq may be called from any
+// goroutine.
+func NewLeader(set *SortedSet, backlog int) *Leader {
+	if backlog < 1 {
+		backlog = 1
+	}
+	l := &Leader{set: set, codec: set.valueCodec(), size: backlog}
+	l.cond = sync.NewCond(&l.mu)
+	l.l = set.listen(l.record)
+	l.l.expiry = true
+	return l
+}
+
+func (l *Leader) record(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	buf := make([]byte, walRecordHeaderSize+8, 64)
+	binary.BigEndian.PutUint64(buf[walRecordHeaderSize:], l.seq+1)
+	buf, err := appendWALPayload(buf, e.Key, e.Node, l.codec)
+	if err != nil {
+		l.err = fmt.Errorf("replicating %q: %w", e.Key, err)
+		l.cond.Broadcast()
+		return
+	}
+
+	l.seq++
+	if len(l.backlog) == l.size {
+		// drop the oldest record, moving the others once the array is half wasted
+		l.backlog[0] = nil
+		l.backlog = l.backlog[1:]
+		if cap(l.backlog) > 2*l.size {
+			l.backlog = append(make([][]byte, 0, 2*l.size), l.backlog...)
+		}
+	}
+	l.backlog = append(l.backlog, sealWALRecord(buf, 0))
+	l.cond.Broadcast()
+}
+
+// Seq Get the number of the last change of the set
+func (l *Leader) Seq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// Snapshot Encode the set with MarshalBinary, and return the number of the
+// last change it includes. A follower resynced from the snapshot, see
+// Follower.Resync, is served the changes following that number.
+//
+// Time complexity of this method is : O(N)
+func (l *Leader) Snapshot() ([]byte, uint64, error) {
+	data, err := l.set.MarshalBinary() // may evict expired elements, which are changes
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, l.Seq(), nil
+}
+
+// Serve Write to w the changes following offset, then every later change as
+// soon as it is made, until writing fails, ctx is done or the leader is
+// closed. In the second case ctx.Err() is returned.
+//
+// ErrResyncRequired is returned right away if the changes following offset
+// are no longer in the backlog, or if offset is ahead of the leader.
+func (l *Leader) Serve(ctx context.Context, w io.Writer, offset uint64) error {
+	l.mu.Lock()
+	ahead := offset > l.seq
+	l.mu.Unlock()
+	if ahead {
+		return ErrResyncRequired
+	}
+
+	// wake up the loop below when ctx is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.cond.Broadcast()
+			l.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	next := offset + 1
+	for {
+		l.mu.Lock()
+		for next > l.seq && !l.closed && l.err == nil && ctx.Err() == nil {
+			l.cond.Wait()
+		}
+		if err := ctx.Err(); err != nil {
+			l.mu.Unlock()
+			return err
+		}
+		if l.closed {
+			l.mu.Unlock()
+			return ErrLeaderClosed
+		}
+		if l.err != nil {
+			l.mu.Unlock()
+			return l.err
+		}
+		first := l.seq + 1 - uint64(len(l.backlog))
+		if next < first {
+			l.mu.Unlock()
+			return ErrResyncRequired
+		}
+		records := append([][]byte(nil), l.backlog[next-first:]...)
+		next = l.seq + 1
+		l.mu.Unlock()
+
+		buf := make([]byte, 0, 4096)
+		for _, record := range records {
+			buf = append(buf, record...)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// Close Stop numbering the changes of the set, Serve returns ErrLeaderClosed
+func (l *Leader) Close() {
+	l.set.unlisten(l.l)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	l.cond.Broadcast()
+}
+
+// Follower is a replica of a SortedSet kept up to date by applying the
+// stream of a Leader. Its set must only be read through View while changes
+// are applied.
+type Follower struct {
+	mu    sync.RWMutex
+	set   *SortedSet
+	codec ValueCodec
+	seq   uint64 // number of the last applied change
+}
+
+// NewFollower Create an empty replica, with the given options for its set.
+// It must be resynced unless the leader also started from an empty set.
+func NewFollower(options ...Option) *Follower {
+	set := New(options...)
+	return &Follower{set: set, codec: set.valueCodec()}
+}
+
+// Seq Get the number of the last applied change, the offset to give to
+// Leader.Serve
+func (f *Follower) Seq() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.seq
+}
+
+// View call fn with the set of the follower, no change being applied until
+// fn returns. fn must not change the set.
+func (f *Follower) View(fn func(set *SortedSet)) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	fn(f.set)
+}
+
+// Resync Replace the content of the set with a snapshot of the leader, which
+// includes the changes up to seq, see Leader.Snapshot
+func (f *Follower) Resync(snapshot []byte, seq uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.set.UnmarshalBinary(snapshot); err != nil {
+		return err
+	}
+	f.seq = seq
+	return nil
+}
+
+// Apply Read the changes written by Leader.Serve from r and apply them, until
+// r returns io.EOF between two changes, in which case nil is returned.
+//
+// Changes which were already applied are skipped, so a follower can reconnect
+// with an older offset. ErrReplicationGap is returned if a change is missing,
+// ErrInvalidData if the stream is corrupted.
+func (f *Follower) Apply(r io.Reader) error {
+	var header [walRecordHeaderSize]byte
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size < 8+1 {
+			return ErrInvalidData
+		}
+		if cap(payload) < int(size) {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			return fmt.Errorf("%w: bad checksum", ErrInvalidData)
+		}
+		if err := f.apply(binary.BigEndian.Uint64(payload), payload[8:]); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *Follower) apply(seq uint64, payload []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case seq <= f.seq:
+		return nil // already applied
+	case seq != f.seq+1:
+		return fmt.Errorf("%w: expected change %d, got %d", ErrReplicationGap, f.seq+1, seq)
+	}
+	if err := applyWALPayload(f.set, payload, f.codec, false); err != nil {
+		return fmt.Errorf("%w: change %d: %v", ErrInvalidData, seq, err)
+	}
+	f.seq = seq
+	return nil
+}
@@ -0,0 +1,214 @@
+package sortedset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+/* Wait until the follower applied the change seq. */
+func waitSeq(t *testing.T, f *Follower, seq uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Seq() < seq {
+		if time.Now().After(deadline) {
+			t.Fatalf("follower is at change %d, expected %d", f.Seq(), seq)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func checkReplica(t *testing.T, f *Follower, leader *SortedSet) {
+	t.Helper()
+	f.View(func(set *SortedSet) {
+		checkSameNodes(t, set, leader)
+		checkRanks(t, set)
+	})
+}
+
+func TestReplication(t *testing.T) {
+	set := New(WithMaxSize(50, EvictMin))
+	set.AddOrUpdate("before", 1, "not streamed")
+	leader := NewLeader(set, 1000)
+	defer leader.Close()
+
+	follower := NewFollower()
+	data, seq, err := leader.Snapshot()
+	if err != nil || seq != 0 {
+		t.Fatalf("Snapshot() = %d %v", seq, err)
+	}
+	if err := follower.Resync(data, seq); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- leader.Serve(ctx, w, follower.Seq())
+	}()
+	applied := make(chan error, 1)
+	go func() {
+		applied <- follower.Apply(r)
+	}()
+
+	for i := 0; i < 100; i++ {
+		set.AddOrUpdate(fmt.Sprint("key", i%70), float64(i), i) // evicts once full
+	}
+	set.Remove("key60")
+	set.PopMin()
+	set.IncrBy("key65", 1000)
+	waitSeq(t, follower, leader.Seq())
+	checkReplica(t, follower, set)
+
+	cancel()
+	if err := <-served; err != context.Canceled {
+		t.Errorf("Serve() = %v, expected context.Canceled", err)
+	}
+	w.Close()
+	if err := <-applied; err != nil {
+		t.Errorf("Apply() = %v, expected the end of the stream", err)
+	}
+
+	// reconnect with an older offset, already applied changes are skipped
+	set.AddOrUpdate("after", 5, nil)
+	r, w = io.Pipe()
+	go leader.Serve(context.Background(), w, follower.Seq()-10)
+	go follower.Apply(r)
+	waitSeq(t, follower, leader.Seq())
+	checkReplica(t, follower, set)
+	r.Close() // Serve returns once the leader is closed
+}
+
+/* Followers keep TTL'd elements until the leader evicts them, whatever their
+ * own clock, so they can be viewed concurrently. Run it with -race. */
+func TestReplicationTTL(t *testing.T) {
+	now := time.Unix(1000, 0) // long expired for the clock of the follower
+	set := New(WithClock(func() time.Time { return now }))
+	leader := NewLeader(set, 100)
+	follower := NewFollower()
+	r, w := io.Pipe()
+	go leader.Serve(context.Background(), w, 0)
+	go follower.Apply(r)
+	defer leader.Close()
+
+	for i := 0; i < 10; i++ {
+		set.AddOrUpdateWithTTL(fmt.Sprint("key", i), float64(i), nil, time.Duration(i%2+1)*time.Second)
+	}
+	waitSeq(t, follower, 10)
+
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 100; j++ {
+				follower.View(func(set *SortedSet) {
+					if count := set.GetCount(); count != 10 {
+						t.Errorf("follower has %d elements, expected 10", count)
+					}
+					set.GetByRankRange(1, -1, false)
+				})
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	checkReplica(t, follower, set)
+
+	now = now.Add(time.Second)
+	set.EvictExpired(now)
+	waitSeq(t, follower, 15)
+	checkReplica(t, follower, set)
+	if set.GetCount() != 5 {
+		t.Errorf("leader has %d elements, expected 5", set.GetCount())
+	}
+}
+
+func TestReplicationResync(t *testing.T) {
+	set := New()
+	leader := NewLeader(set, 5)
+	follower := NewFollower()
+
+	for i := 0; i < 10; i++ {
+		set.AddOrUpdate(fmt.Sprint("key", i), float64(i), nil)
+	}
+	if err := leader.Serve(context.Background(), io.Discard, 3); err != ErrResyncRequired {
+		t.Errorf("Serve() of a change out of the backlog = %v, expected ErrResyncRequired", err)
+	}
+	if err := leader.Serve(context.Background(), io.Discard, 11); err != ErrResyncRequired {
+		t.Errorf("Serve() of an offset ahead of the leader = %v, expected ErrResyncRequired", err)
+	}
+
+	data, seq, _ := leader.Snapshot()
+	follower.Resync(data, seq)
+	set.AddOrUpdate("key0", 100, nil)
+	r, w := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- leader.Serve(context.Background(), w, seq)
+	}()
+	go follower.Apply(r)
+	waitSeq(t, follower, 11)
+	checkReplica(t, follower, set)
+
+	leader.Close()
+	if err := <-served; err != ErrLeaderClosed {
+		t.Errorf("Serve() = %v, expected ErrLeaderClosed", err)
+	}
+	set.AddOrUpdate("key1", 100, nil)
+	if leader.Seq() != 11 {
+		t.Errorf("a closed leader numbered a change")
+	}
+}
+
+func TestReplicationGap(t *testing.T) {
+	set := New()
+	leader := NewLeader(set, 10)
+	for i := 0; i < 5; i++ {
+		set.AddOrUpdate(fmt.Sprint("key", i), float64(i), nil)
+	}
+	leader.Close()
+
+	// a follower missing the first 2 changes
+	r, w := io.Pipe()
+	go func() {
+		leader.mu.Lock()
+		records := leader.backlog[2:]
+		leader.mu.Unlock()
+		for _, record := range records {
+			w.Write(record)
+		}
+		w.Close()
+	}()
+	follower := NewFollower()
+	if err := follower.Apply(r); !errors.Is(err, ErrReplicationGap) || follower.Seq() != 0 {
+		t.Errorf("Apply() = %v at %d, expected ErrReplicationGap", err, follower.Seq())
+	}
+
+	// corrupted and torn records
+	record := append([]byte(nil), leader.backlog[0]...)
+	record[len(record)-1] ^= 0xff
+	if err := NewFollower().Apply(bytesReader(record)); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("Apply() of a corrupted record = %v, expected ErrInvalidData", err)
+	}
+	if err := NewFollower().Apply(bytesReader(leader.backlog[0][:10])); err != io.ErrUnexpectedEOF {
+		t.Errorf("Apply() of a torn record = %v, expected io.ErrUnexpectedEOF", err)
+	}
+	follower = NewFollower()
+	if err := follower.Apply(bytesReader(leader.backlog[0])); err != nil || follower.Seq() != 1 {
+		t.Errorf("Apply() = %v at %d, expected to apply change 1", err, follower.Seq())
+	}
+}
+
+func bytesReader(data []byte) io.Reader {
+	r, w := io.Pipe()
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+	return r
+}
@@ -0,0 +1,241 @@
+// Package scheduler provides a delayed-job queue built on top of a SortedSet.
+//
+// Jobs are kept in a SortedSet keyed by job ID and scored by the time they
+// are due, so the next job to run is always PeekMin(). A single timer is reset
+// to the due time of that job, and due jobs are delivered on a channel.
+//
+//	s := scheduler.New()
+//	defer s.Close()
+//
+//	s.Schedule("job-1", time.Now().Add(time.Minute), payload)
+//	s.Reschedule("job-1", time.Now().Add(time.Hour))
+//	s.Cancel("job-1")
+//
+//	for job := range s.C() {
+//	    // job.ID is due
+//	}
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+// Job is a unit of work delivered by the Scheduler once it is due
+type Job struct {
+	ID      string
+	At      time.Time   // time the job is due
+	Payload interface{} // data given to Schedule
+}
+
+// Clock abstracts the passing of time so the Scheduler can be tested without sleeping
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of *time.Timer used by the Scheduler
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Option configures a Scheduler created by New
+type Option func(s *Scheduler)
+
+// WithClock make the Scheduler use clock instead of the system clock
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithBuffer set the capacity of the channel returned by C, which is unbuffered by default
+func WithBuffer(size int) Option {
+	return func(s *Scheduler) {
+		s.c = make(chan Job, size)
+	}
+}
+
+// Scheduler delivers jobs on a channel at the time they are scheduled for.
+// It is safe for concurrent use by multiple goroutines.
+type Scheduler struct {
+	mu    sync.Mutex
+	clock Clock
+	jobs  *sortedset.SortedSet // keyed by job ID, scored by due time in Unix nanoseconds
+	c     chan Job
+	wake  chan struct{} // signals the run loop that the earliest job may have changed
+	done  chan struct{}
+	once  sync.Once
+}
+
+// New Create a new Scheduler and start delivering jobs. Close must be called
+// to release its goroutine.
+func New(options ...Option) *Scheduler {
+	s := &Scheduler{
+		clock: systemClock{},
+		jobs:  sortedset.New(),
+		c:     make(chan Job),
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	go s.run()
+	return s
+}
+
+// C return the channel on which due jobs are delivered, in order of due time.
+// It is closed by Close.
+func (s *Scheduler) C() <-chan Job {
+	return s.c
+}
+
+// Len Get the number of jobs which are not due yet
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs.GetCount()
+}
+
+// Schedule add a job due at the given time, or replace the job with the same ID.
+// It returns true if the job is new
+func (s *Scheduler) Schedule(id string, at time.Time, payload interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	added := s.jobs.AddOrUpdate(id, score(at), &Job{ID: id, At: at, Payload: payload})
+	s.notify()
+	return added
+}
+
+// Reschedule change the due time of a job, keeping its payload.
+// It returns false if the job does not exist or was already delivered
+func (s *Scheduler) Reschedule(id string, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node := s.jobs.GetByKey(id)
+	if node == nil {
+		return false
+	}
+	job := *node.Value.(*Job)
+	job.At = at
+	s.jobs.AddOrUpdate(id, score(at), &job)
+	s.notify()
+	return true
+}
+
+// Cancel remove a job so it is never delivered.
+// It returns false if the job does not exist or was already delivered
+func (s *Scheduler) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobs.Remove(id) == nil {
+		return false
+	}
+	s.notify()
+	return true
+}
+
+// Close stop delivering jobs and close the channel returned by C.
+// Jobs which are not due yet are dropped
+func (s *Scheduler) Close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+func score(at time.Time) float64 {
+	return float64(at.UnixNano())
+}
+
+/* Wake the run loop up, the lock must be held. */
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default: // a wake up is already pending
+	}
+}
+
+/* Remove the earliest job if it is due. Otherwise return how long to wait
+ * for it, or a negative duration if there is no job at all. */
+func (s *Scheduler) popDue() (*Job, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node := s.jobs.PeekMin()
+	if node == nil {
+		return nil, -1
+	}
+	job := node.Value.(*Job)
+	if wait := job.At.Sub(s.clock.Now()); wait > 0 {
+		return nil, wait
+	}
+	s.jobs.Remove(job.ID)
+	return job, 0
+}
+
+func (s *Scheduler) run() {
+	defer close(s.c)
+
+	var timer Timer
+	for {
+		job, wait := s.popDue()
+		if job != nil {
+			select {
+			case s.c <- *job:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+
+		var expired <-chan time.Time
+		if wait > 0 {
+			if timer == nil {
+				timer = s.clock.NewTimer(wait)
+			} else {
+				timer.Reset(wait)
+			}
+			expired = timer.C()
+		}
+
+		select {
+		case <-expired:
+		case <-s.wake:
+		case <-s.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+
+		// make sure the timer is stopped and drained before it is reset
+		if timer != nil && expired != nil && !timer.Stop() {
+			select {
+			case <-timer.C():
+			default:
+			}
+		}
+	}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
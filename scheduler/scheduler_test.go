@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves forward when Advance is called
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	c      chan time.Time
+	at     time.Time
+	active bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), at: c.now.Add(d), active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.active = false
+			t.c <- c.now
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.at = t.clock.now.Add(d)
+	t.active = true
+	return active
+}
+
+func expectJob(t *testing.T, s *Scheduler, id string) {
+	t.Helper()
+	select {
+	case job := <-s.C():
+		if job.ID != id {
+			t.Errorf("received job %q, expected %q", job.ID, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("job %q was not delivered", id)
+	}
+}
+
+func expectNoJob(t *testing.T, s *Scheduler) {
+	t.Helper()
+	select {
+	case job := <-s.C():
+		t.Errorf("job %q should not be delivered yet", job.ID)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	clock := newFakeClock()
+	s := New(WithClock(clock))
+	defer s.Close()
+
+	now := clock.Now()
+	s.Schedule("a", now.Add(10*time.Second), "payload")
+	s.Schedule("b", now.Add(5*time.Second), nil)
+	s.Schedule("c", now.Add(7*time.Second), nil)
+	expectNoJob(t, s)
+
+	clock.Advance(5 * time.Second)
+	expectJob(t, s, "b")
+	expectNoJob(t, s)
+
+	if !s.Cancel("c") {
+		t.Error("Cancel() should return true for a pending job")
+	}
+	if s.Cancel("b") {
+		t.Error("Cancel() should return false for a delivered job")
+	}
+	if !s.Reschedule("a", now.Add(20*time.Second)) {
+		t.Error("Reschedule() should return true for a pending job")
+	}
+
+	clock.Advance(10 * time.Second)
+	expectNoJob(t, s)
+
+	clock.Advance(5 * time.Second)
+	select {
+	case job := <-s.C():
+		if job.ID != "a" || job.Payload != "payload" || !job.At.Equal(now.Add(20*time.Second)) {
+			t.Errorf("unexpected job %+v", job)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job \"a\" was not delivered")
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() returns %d, expected 0", s.Len())
+	}
+}
+
+func TestSchedulerPastJobs(t *testing.T) {
+	clock := newFakeClock()
+	s := New(WithClock(clock), WithBuffer(2))
+
+	s.Schedule("late", clock.Now().Add(-time.Second), nil)
+	s.Schedule("now", clock.Now(), nil)
+	expectJob(t, s, "late")
+	expectJob(t, s, "now")
+
+	s.Close()
+	if _, ok := <-s.C(); ok {
+		t.Error("C() should be closed after Close()")
+	}
+}
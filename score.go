@@ -0,0 +1,19 @@
+package sortedset
+
+import (
+	"math"
+	"strconv"
+)
+
+// FormatScore format a score the way Redis does, with the shortest
+// representation which parses back to the same value with
+// strconv.ParseFloat, and "inf" / "-inf" for infinite scores
+func FormatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'g', -1, 64)
+}
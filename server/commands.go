@@ -0,0 +1,694 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+// Status is a reply made of a simple string, like OK
+type Status string
+
+// Map is a reply made of alternating keys and values, sent as a map in
+// RESP3 and as a flat array in RESP2
+type Map []interface{}
+
+// Member is a member of a sorted set with its score, replied by the commands
+// WITHSCORES. A []Member is sent as an array of pairs in RESP3 and as a flat
+// array in RESP2.
+type Member struct {
+	Key   string
+	Score float64
+}
+
+var (
+	errSyntax      = errors.New("ERR syntax error")
+	errNotFloat    = errors.New("ERR value is not a valid float")
+	errNotInteger  = errors.New("ERR value is not an integer or out of range")
+	errNaN         = errors.New("ERR resulting score is not a number (NaN)")
+	errMinMaxFloat = errors.New("ERR min or max is not a float")
+	errMinMaxLex   = errors.New("ERR min or max not valid string range item")
+)
+
+type command struct {
+	fn    func(s *Server, args []string) interface{}
+	arity int // number of arguments including the name, or its opposite for a minimum
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":     {(*Server).ping, -1},
+		"echo":     {(*Server).echo, 2},
+		"command":  {(*Server).command, -1},
+		"config":   {(*Server).config, -2},
+		"client":   {(*Server).client, -2},
+		"select":   {(*Server).selectDB, 2},
+		"del":      {(*Server).del, -2},
+		"exists":   {(*Server).exists, -2},
+		"flushall": {(*Server).flushAll, -1},
+		"zadd":     {(*Server).zadd, -4},
+		"zincrby":  {(*Server).zincrby, 4},
+		"zrem":     {(*Server).zrem, -3},
+		"zrank":    {(*Server).zrank, -3},
+		"zrevrank": {(*Server).zrevrank, -3},
+		"zscore":   {(*Server).zscore, 3},
+		"zrange":   {(*Server).zrange, -4},
+		"zcount":   {(*Server).zcount, 4},
+		"zpopmin":  {(*Server).zpopmin, -2},
+		"zpopmax":  {(*Server).zpopmax, -2},
+		"zcard":    {(*Server).zcard, 2},
+	}
+}
+
+// Exec execute a command, given as its name and arguments, and return its
+// reply: nil, Status, error, int64, string, float64, []interface{}, Map or
+// []Member. It lets the sets of the server be queried without a connection.
+//
+// HELLO and QUIT only make sense on a connection and are not supported.
+func (s *Server) Exec(args ...string) interface{} {
+	if len(args) == 0 {
+		return errors.New("ERR empty command")
+	}
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("ERR unknown command '%s'", args[0])
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		return fmt.Errorf("ERR wrong number of arguments for '%s' command", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cmd.fn(s, args)
+}
+
+/* Get the set stored at key, creating it if create is true. */
+func (s *Server) set(key string, create bool) *sortedset.SortedSet {
+	set := s.sets[key]
+	if set == nil && create {
+		set = sortedset.New(s.setOptions...)
+		s.sets[key] = set
+	}
+	return set
+}
+
+/* Delete the key of a set which became empty. */
+func (s *Server) dropIfEmpty(key string) {
+	if set := s.sets[key]; set != nil && set.GetCount() == 0 {
+		delete(s.sets, key)
+	}
+}
+
+func (s *Server) ping(args []string) interface{} {
+	if len(args) > 2 {
+		return fmt.Errorf("ERR wrong number of arguments for 'ping' command")
+	}
+	if len(args) == 2 {
+		return args[1]
+	}
+	return Status("PONG")
+}
+
+func (s *Server) echo(args []string) interface{} {
+	return args[1]
+}
+
+/* COMMAND is sent by redis-cli on startup, an empty reply is enough. */
+func (s *Server) command(args []string) interface{} {
+	return []interface{}{}
+}
+
+/* CONFIG GET is sent by redis-benchmark, there is no configuration to report. */
+func (s *Server) config(args []string) interface{} {
+	if strings.EqualFold(args[1], "get") {
+		return Map{}
+	}
+	return errors.New("ERR CONFIG subcommand not supported")
+}
+
+/* CLIENT SETNAME and friends are sent by client libraries on connection. */
+func (s *Server) client(args []string) interface{} {
+	return Status("OK")
+}
+
+func (s *Server) selectDB(args []string) interface{} {
+	if args[1] != "0" {
+		return errors.New("ERR DB index is out of range")
+	}
+	return Status("OK")
+}
+
+func (s *Server) del(args []string) interface{} {
+	var deleted int64
+	for _, key := range args[1:] {
+		if _, ok := s.sets[key]; ok {
+			delete(s.sets, key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+func (s *Server) exists(args []string) interface{} {
+	var count int64
+	for _, key := range args[1:] {
+		if _, ok := s.sets[key]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) flushAll(args []string) interface{} {
+	s.sets = make(map[string]*sortedset.SortedSet)
+	return Status("OK")
+}
+
+/* ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...] */
+func (s *Server) zadd(args []string) interface{} {
+	var nx, xx, gt, lt, ch, incr bool
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "gt":
+			gt = true
+		case "lt":
+			lt = true
+		case "ch":
+			ch = true
+		case "incr":
+			incr = true
+		default:
+			break flags
+		}
+	}
+	if nx && xx {
+		return errors.New("ERR XX and NX options at the same time are not compatible")
+	}
+	if (gt && lt) || (nx && (gt || lt)) {
+		return errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errSyntax
+	}
+	if incr && len(pairs) > 2 {
+		return errors.New("ERR INCR option supports a single increment-element pair")
+	}
+	scores := make([]float64, len(pairs)/2)
+	for j := range scores {
+		score, err := parseFloat(pairs[2*j])
+		if err != nil {
+			return errNotFloat
+		}
+		scores[j] = score
+	}
+
+	key := args[1]
+	var added, changed int64
+	var result interface{}
+	for j, score := range scores {
+		member := pairs[2*j+1]
+		set := s.set(key, false)
+		var node *sortedset.Node
+		if set != nil {
+			node = set.GetByKey(member)
+		}
+
+		if node == nil {
+			if xx {
+				continue
+			}
+			s.set(key, true).AddOrUpdate(member, score, nil)
+			added++
+			result = score
+			continue
+		}
+		if nx {
+			continue
+		}
+		current := node.Score()
+		if incr {
+			score += current
+			if math.IsNaN(score) {
+				return errNaN
+			}
+		}
+		if (gt && score <= current) || (lt && score >= current) {
+			continue
+		}
+		result = score
+		if score != current {
+			set.AddOrUpdate(member, score, node.Value)
+			changed++
+		}
+	}
+
+	if incr {
+		return result
+	}
+	if ch {
+		return added + changed
+	}
+	return added
+}
+
+/* ZINCRBY key increment member */
+func (s *Server) zincrby(args []string) interface{} {
+	delta, err := parseFloat(args[2])
+	if err != nil {
+		return errNotFloat
+	}
+	set := s.set(args[1], true)
+	if node := set.GetByKey(args[3]); node != nil && math.IsNaN(node.Score()+delta) {
+		return errNaN
+	}
+	return set.IncrBy(args[3], delta)
+}
+
+/* ZREM key member [member ...] */
+func (s *Server) zrem(args []string) interface{} {
+	set := s.set(args[1], false)
+	if set == nil {
+		return int64(0)
+	}
+	var removed int64
+	for _, member := range args[2:] {
+		if set.Remove(member) != nil {
+			removed++
+		}
+	}
+	s.dropIfEmpty(args[1])
+	return removed
+}
+
+/* ZRANK key member [WITHSCORE] */
+func (s *Server) zrank(args []string) interface{} {
+	return s.rank(args, false)
+}
+
+/* ZREVRANK key member [WITHSCORE] */
+func (s *Server) zrevrank(args []string) interface{} {
+	return s.rank(args, true)
+}
+
+func (s *Server) rank(args []string, rev bool) interface{} {
+	withScore := false
+	if len(args) == 4 && strings.EqualFold(args[3], "withscore") {
+		withScore = true
+	} else if len(args) != 3 {
+		return errSyntax
+	}
+
+	set := s.set(args[1], false)
+	if set == nil {
+		return nil
+	}
+	rank := set.FindRank(args[2])
+	if rank == 0 {
+		return nil
+	}
+	reply := int64(rank - 1)
+	if rev {
+		reply = int64(set.GetCount() - rank)
+	}
+	if withScore {
+		return []interface{}{reply, set.GetByKey(args[2]).Score()}
+	}
+	return reply
+}
+
+/* ZSCORE key member */
+func (s *Server) zscore(args []string) interface{} {
+	if set := s.set(args[1], false); set != nil {
+		if node := set.GetByKey(args[2]); node != nil {
+			return node.Score()
+		}
+	}
+	return nil
+}
+
+/* ZCARD key */
+func (s *Server) zcard(args []string) interface{} {
+	if set := s.set(args[1], false); set != nil {
+		return int64(set.GetCount())
+	}
+	return int64(0)
+}
+
+/* ZCOUNT key min max */
+func (s *Server) zcount(args []string) interface{} {
+	min, max, err := parseScoreRange(args[2], args[3])
+	if err != nil {
+		return err
+	}
+	set := s.set(args[1], false)
+	if set == nil {
+		return int64(0)
+	}
+	// count by the ranks of the first and the last node, without walking the range
+	first := scoreRange(set, min, max, false, 0, 1)
+	if len(first) == 0 {
+		return int64(0)
+	}
+	last := scoreRange(set, min, max, true, 0, 1)
+	return int64(set.FindRank(last[0].Key()) - set.FindRank(first[0].Key()) + 1)
+}
+
+/* ZPOPMIN key [count] */
+func (s *Server) zpopmin(args []string) interface{} {
+	return s.pop(args, false)
+}
+
+/* ZPOPMAX key [count] */
+func (s *Server) zpopmax(args []string) interface{} {
+	return s.pop(args, true)
+}
+
+func (s *Server) pop(args []string, max bool) interface{} {
+	if len(args) > 3 {
+		return errSyntax
+	}
+	count := 1
+	if len(args) == 3 {
+		n, err := strconv.Atoi(args[2])
+		if err != nil {
+			return errNotInteger
+		}
+		if n < 0 {
+			return errors.New("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	set := s.set(args[1], false)
+	members := []Member{}
+	for set != nil && len(members) < count {
+		var node *sortedset.Node
+		if max {
+			node = set.PopMax()
+		} else {
+			node = set.PopMin()
+		}
+		if node == nil {
+			break
+		}
+		members = append(members, Member{node.Key(), node.Score()})
+	}
+	s.dropIfEmpty(args[1])
+
+	if len(args) == 2 { // a single member is replied as a flat pair even in RESP3
+		if len(members) == 0 {
+			return []interface{}{}
+		}
+		return []interface{}{members[0].Key, members[0].Score}
+	}
+	return members
+}
+
+/* ZRANGE key start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES] */
+func (s *Server) zrange(args []string) interface{} {
+	var byScore, byLex, rev, withScores, limited bool
+	offset, count := 0, -1
+	for i := 4; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "byscore":
+			byScore = true
+		case "bylex":
+			byLex = true
+		case "rev":
+			rev = true
+		case "withscores":
+			withScores = true
+		case "limit":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(args[i+1])
+			count, err2 = strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return errNotInteger
+			}
+			limited = true
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+	if byScore && byLex {
+		return errSyntax
+	}
+	if limited && !byScore && !byLex {
+		return errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && byLex {
+		return errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	start, stop := args[2], args[3]
+	if rev && (byScore || byLex) { // ranges are given from max to min
+		start, stop = stop, start
+	}
+
+	var nodes []*sortedset.Node
+	set := s.set(args[1], false)
+	switch {
+	case byScore:
+		min, max, err := parseScoreRange(start, stop)
+		if err != nil {
+			return err
+		}
+		if set != nil && offset >= 0 {
+			nodes = scoreRange(set, min, max, rev, offset, count)
+		}
+	case byLex:
+		min, max, err := parseLexRange(start, stop)
+		if err != nil {
+			return err
+		}
+		if set != nil && offset >= 0 {
+			nodes = lexRange(set, min, max, rev, offset, count)
+		}
+	default:
+		startIndex, err1 := strconv.Atoi(start)
+		stopIndex, err2 := strconv.Atoi(stop)
+		if err1 != nil || err2 != nil {
+			return errNotInteger
+		}
+		if set != nil {
+			nodes = rankRange(set, startIndex, stopIndex, rev)
+		}
+	}
+
+	if withScores {
+		members := make([]Member, len(nodes))
+		for i, node := range nodes {
+			members[i] = Member{node.Key(), node.Score()}
+		}
+		return members
+	}
+	keys := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		keys[i] = node.Key()
+	}
+	return keys
+}
+
+/* Nodes between 0-based indexes start and stop, negative ones counting from
+ * the end, in descending order if rev is true. */
+func rankRange(set *sortedset.SortedSet, start, stop int, rev bool) []*sortedset.Node {
+	n := set.GetCount()
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= n {
+		return nil
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if rev {
+		return set.GetByRankRange(n-start, n-stop, false)
+	}
+	return set.GetByRankRange(start+1, stop+1, false)
+}
+
+type scoreBound struct {
+	value     float64
+	exclusive bool
+}
+
+func (b scoreBound) belowOrAt(score float64) bool {
+	return b.value < score || (!b.exclusive && b.value == score)
+}
+
+func (b scoreBound) aboveOrAt(score float64) bool {
+	return b.value > score || (!b.exclusive && b.value == score)
+}
+
+func parseScoreRange(min, max string) (scoreBound, scoreBound, error) {
+	parse := func(s string) (scoreBound, error) {
+		var b scoreBound
+		if strings.HasPrefix(s, "(") {
+			b.exclusive = true
+			s = s[1:]
+		}
+		var err error
+		if b.value, err = parseFloat(s); err != nil {
+			return b, errMinMaxFloat
+		}
+		return b, nil
+	}
+	minBound, err := parse(min)
+	if err != nil {
+		return minBound, minBound, err
+	}
+	maxBound, err := parse(max)
+	return minBound, maxBound, err
+}
+
+/* Nodes whose score is within [min, max], skipping offset of them and
+ * returning at most count if it is not negative. */
+func scoreRange(set *sortedset.SortedSet, min, max scoreBound, rev bool, offset, count int) []*sortedset.Node {
+	if min.value > max.value || (min.value == max.value && (min.exclusive || max.exclusive)) {
+		return nil
+	}
+
+	// first node in the range, from which the range is walked
+	var x *sortedset.Node
+	if !rev {
+		nodes := set.GetByScoreRange(min.value, max.value, &sortedset.GetByScoreRangeOptions{
+			Limit: 1, ExcludeStart: min.exclusive, ExcludeEnd: max.exclusive,
+		})
+		if len(nodes) > 0 {
+			x = nodes[0]
+		}
+	} else if min.value < max.value {
+		nodes := set.GetByScoreRange(max.value, min.value, &sortedset.GetByScoreRangeOptions{
+			Limit: 1, ExcludeStart: max.exclusive, ExcludeEnd: min.exclusive,
+		})
+		if len(nodes) > 0 {
+			x = nodes[0]
+		}
+	} else if above := set.GetByScoreRange(max.value, math.Inf(1), &sortedset.GetByScoreRangeOptions{
+		Limit: 1, ExcludeStart: true,
+	}); len(above) > 0 {
+		x = above[0].Previous()
+	} else {
+		x = set.GetByRank(-1, false)
+	}
+
+	// GetByScoreRange considers scores within eps as equal, skip the nodes
+	// it includes beyond the exact bound
+	for x != nil && !rev && !min.belowOrAt(x.Score()) || x != nil && rev && !max.aboveOrAt(x.Score()) {
+		x = next(x, rev)
+	}
+
+	var nodes []*sortedset.Node
+	for ; x != nil && min.belowOrAt(x.Score()) && max.aboveOrAt(x.Score()) && count != 0; x = next(x, rev) {
+		if offset > 0 {
+			offset--
+			continue
+		}
+		nodes = append(nodes, x)
+		count--
+	}
+	return nodes
+}
+
+type lexBound struct {
+	value     string
+	exclusive bool
+	infinite  int // -1 for "-" and 1 for "+"
+}
+
+func (b lexBound) belowOrAt(key string) bool {
+	return b.infinite < 0 || (b.infinite == 0 && (b.value < key || (!b.exclusive && b.value == key)))
+}
+
+func (b lexBound) aboveOrAt(key string) bool {
+	return b.infinite > 0 || (b.infinite == 0 && (b.value > key || (!b.exclusive && b.value == key)))
+}
+
+func parseLexRange(min, max string) (lexBound, lexBound, error) {
+	parse := func(s string) (lexBound, error) {
+		switch {
+		case s == "-":
+			return lexBound{infinite: -1}, nil
+		case s == "+":
+			return lexBound{infinite: 1}, nil
+		case strings.HasPrefix(s, "["):
+			return lexBound{value: s[1:]}, nil
+		case strings.HasPrefix(s, "("):
+			return lexBound{value: s[1:], exclusive: true}, nil
+		}
+		return lexBound{}, errMinMaxLex
+	}
+	minBound, err := parse(min)
+	if err != nil {
+		return minBound, minBound, err
+	}
+	maxBound, err := parse(max)
+	return minBound, maxBound, err
+}
+
+/* Nodes whose key is within [min, max]. Like in Redis, the members are
+ * expected to share the same score, so they are ordered by key. The set is
+ * walked from one end, which takes O(N). */
+func lexRange(set *sortedset.SortedSet, min, max lexBound, rev bool, offset, count int) []*sortedset.Node {
+	x := set.PeekMin()
+	if rev {
+		x = set.PeekMax()
+	}
+	var nodes []*sortedset.Node
+	for ; x != nil && count != 0; x = next(x, rev) {
+		if !rev && !min.belowOrAt(x.Key()) || rev && !max.aboveOrAt(x.Key()) {
+			continue // not reached the range yet
+		}
+		if !rev && !max.aboveOrAt(x.Key()) || rev && !min.belowOrAt(x.Key()) {
+			break
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		nodes = append(nodes, x)
+		count--
+	}
+	return nodes
+}
+
+func next(x *sortedset.Node, rev bool) *sortedset.Node {
+	if rev {
+		return x.Previous()
+	}
+	return x.Next()
+}
+
+/* Parse a score like Redis, accepting inf, +inf and -inf but not NaN. */
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
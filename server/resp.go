@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+const (
+	maxArgs       = 1024 * 1024
+	maxBulkLength = 512 * 1024 * 1024
+	maxInlineSize = 64 * 1024
+)
+
+// errProtocol is returned by readCommand when the client does not speak RESP,
+// the connection is closed after replying with it
+type errProtocol string
+
+func (e errProtocol) Error() string {
+	return "ERR Protocol error: " + string(e)
+}
+
+// readCommand read the next command of a client, either an array of bulk
+// strings or an inline command as typed in telnet
+func readCommand(r *bufio.Reader) ([]string, error) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '*' {
+			if args := strings.Fields(line); len(args) > 0 {
+				return args, nil
+			}
+			continue
+		}
+
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count > maxArgs {
+			return nil, errProtocol("invalid multibulk length")
+		}
+		if count <= 0 {
+			continue
+		}
+		args := make([]string, count)
+		for i := range args {
+			line, err := readLine(r)
+			if err != nil {
+				return nil, err
+			}
+			if len(line) == 0 || line[0] != '$' {
+				return nil, errProtocol(fmt.Sprintf("expected '$', got '%.1s'", line))
+			}
+			length, err := strconv.Atoi(line[1:])
+			if err != nil || length < 0 || length > maxBulkLength {
+				return nil, errProtocol("invalid bulk length")
+			}
+			buf, err := readBulk(r, length+2)
+			if err != nil {
+				return nil, err
+			}
+			if buf[length] != '\r' || buf[length+1] != '\n' {
+				return nil, errProtocol("invalid bulk terminator")
+			}
+			args[i] = string(buf[:length])
+		}
+		return args, nil
+	}
+}
+
+/* Read n bytes, growing the buffer with the bytes received rather than
+ * allocating the length announced by the client up front. */
+func readBulk(r *bufio.Reader, n int) ([]byte, error) {
+	if n <= maxInlineSize {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return buf, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/* Read a line terminated by \n or \r\n, without its terminator. */
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxInlineSize {
+			return "", errProtocol("too big inline request")
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// writer encodes replies in RESP2 or RESP3, depending on the protocol
+// version negotiated with HELLO
+type writer struct {
+	*bufio.Writer
+	proto int
+}
+
+func (w *writer) reply(reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.null()
+	case Status:
+		w.WriteString("+" + string(v) + "\r\n")
+	case error:
+		w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(v.Error()) + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case int:
+		w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case string:
+		w.bulk(v)
+	case float64:
+		if w.proto >= 3 {
+			w.WriteString("," + sortedset.FormatScore(v) + "\r\n")
+		} else {
+			w.bulk(sortedset.FormatScore(v))
+		}
+	case []interface{}:
+		w.array(len(v))
+		for _, item := range v {
+			w.reply(item)
+		}
+	case Map:
+		if w.proto >= 3 {
+			w.WriteString("%" + strconv.Itoa(len(v)/2) + "\r\n")
+		} else {
+			w.array(len(v))
+		}
+		for _, item := range v {
+			w.reply(item)
+		}
+	case []Member:
+		// RESP3 nests member and score, RESP2 flattens them
+		if w.proto >= 3 {
+			w.array(len(v))
+			for _, m := range v {
+				w.array(2)
+				w.bulk(m.Key)
+				w.reply(m.Score)
+			}
+		} else {
+			w.array(2 * len(v))
+			for _, m := range v {
+				w.bulk(m.Key)
+				w.reply(m.Score)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("server: unknown reply type %T", reply))
+	}
+}
+
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.WriteString("_\r\n")
+	} else {
+		w.WriteString("$-1\r\n")
+	}
+}
+
+func (w *writer) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n")
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
@@ -0,0 +1,231 @@
+// Package server runs named SortedSets behind a small Redis-compatible server,
+// so existing Redis clients and redis-benchmark can talk to it.
+//
+// The server speaks RESP2 and RESP3 (after HELLO 3) over any net.Listener,
+// TCP or Unix socket, and maps the Z* commands onto one SortedSet per key:
+// ZADD, ZINCRBY, ZREM, ZRANK, ZREVRANK, ZSCORE, ZRANGE, ZCOUNT, ZPOPMIN,
+// ZPOPMAX and ZCARD, along with PING, ECHO, DEL, EXISTS and FLUSHALL.
+//
+//	s := server.New()
+//	go s.ListenAndServe("tcp", "127.0.0.1:6379")
+//	defer s.Close()
+//
+// Members are added with a nil Value and keep their Value when their score
+// is updated. Like in Redis, a key is deleted once its set is empty.
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/axieinfinity/sortedset"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close
+var ErrServerClosed = errors.New("server: closed")
+
+// Option configures a Server created by New
+type Option func(s *Server)
+
+// WithSetOptions create the sets of the server with the given options
+func WithSetOptions(options ...sortedset.Option) Option {
+	return func(s *Server) {
+		s.setOptions = options
+	}
+}
+
+// WithSet serve set under key. The set must not be used by anything else
+// while the server is running.
+func WithSet(key string, set *sortedset.SortedSet) Option {
+	return func(s *Server) {
+		s.sets[key] = set
+	}
+}
+
+// Server serves sorted sets over the RESP protocol. It is safe for concurrent
+// use by multiple goroutines, commands being executed one at a time.
+type Server struct {
+	mu         sync.Mutex
+	sets       map[string]*sortedset.SortedSet
+	setOptions []sortedset.Option
+
+	connMu    sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	nextID    int64
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// New Create a new Server without any set
+func New(options ...Option) *Server {
+	s := &Server{
+		sets:      make(map[string]*sortedset.SortedSet),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// ListenAndServe listen on the network address, "tcp" or "unix", and serve
+// the connections, see Serve
+func (s *Server) ListenAndServe(network, address string) error {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accept the connections of l, serving each one in its own goroutine,
+// until Close is called or l fails. It always returns a non-nil error and
+// closes l.
+func (s *Server) Serve(l net.Listener) error {
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.connMu.Unlock()
+
+	defer func() {
+		s.connMu.Lock()
+		delete(s.listeners, l)
+		s.connMu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.connMu.Lock()
+			closed := s.closed
+			s.connMu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.connMu.Lock()
+		if s.closed {
+			s.connMu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.nextID++
+		id := s.nextID
+		s.wg.Add(1)
+		s.connMu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn, id)
+			s.connMu.Lock()
+			delete(s.conns, conn)
+			s.connMu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// Close stop the listeners, close every connection and wait for their
+// goroutines to return. The sets are kept and can still be used with Exec.
+func (s *Server) Close() error {
+	s.connMu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connMu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+/* Execute the commands of a client until it quits or the connection fails.
+ * Replies are flushed once every pipelined command has been executed. */
+func (s *Server) serveConn(conn io.ReadWriter, id int64) {
+	r := bufio.NewReader(conn)
+	w := &writer{Writer: bufio.NewWriter(conn), proto: 2}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			var protocolErr errProtocol
+			if errors.As(err, &protocolErr) {
+				w.reply(err)
+				w.Flush()
+			}
+			return
+		}
+
+		switch strings.ToLower(args[0]) {
+		case "hello":
+			w.reply(hello(w, id, args))
+		case "quit":
+			w.reply(Status("OK"))
+			w.Flush()
+			return
+		default:
+			w.reply(s.Exec(args...))
+		}
+
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+/* HELLO [protover [AUTH username password] [SETNAME clientname]] switches the
+ * protocol of the connection and describes the server. */
+func hello(w *writer, id int64, args []string) interface{} {
+	proto := w.proto
+	if len(args) > 1 {
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errors.New("ERR Protocol version is not an integer or out of range")
+		}
+		if version < 2 || version > 3 {
+			return errors.New("NOPROTO unsupported protocol version")
+		}
+		proto = version
+		for i := 2; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "auth":
+				i += 2
+			case "setname":
+				i++
+			default:
+				return errSyntax
+			}
+			if i >= len(args) {
+				return errSyntax
+			}
+		}
+	}
+	w.proto = proto
+	return Map{
+		"server", "sortedset",
+		"version", "1.0.0",
+		"proto", proto,
+		"id", id,
+		"mode", "standalone",
+		"role", "master",
+		"modules", []interface{}{},
+	}
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is a minimal RESP client decoding replies into Go values:
+// RESP3 doubles become float64, maps and pairs become []interface{}
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, network, address string) *client {
+	t.Helper()
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(args ...string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	c.conn.Write([]byte(b.String()))
+}
+
+func (c *client) do(t *testing.T, args ...string) interface{} {
+	t.Helper()
+	c.send(args...)
+	return c.read(t)
+}
+
+func (c *client) read(t *testing.T) interface{} {
+	t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return Status(line[1:])
+	case '-':
+		return errors.New(line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case ',':
+		f, _ := strconv.ParseFloat(line[1:], 64)
+		return f
+	case '_':
+		return nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		items := []interface{}{}
+		for i := 0; i < n; i++ {
+			items = append(items, c.read(t))
+		}
+		return items
+	}
+	t.Fatalf("unexpected reply %q", line)
+	return nil
+}
+
+func listen(t *testing.T, s *Server, network, address string) net.Listener {
+	t.Helper()
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return l
+}
+
+func check(t *testing.T, c *client, expected interface{}, args ...string) {
+	t.Helper()
+	if reply := c.do(t, args...); !reflect.DeepEqual(reply, expected) {
+		t.Errorf("%s = %#v, expected %#v", strings.Join(args, " "), reply, expected)
+	}
+}
+
+func TestServer(t *testing.T) {
+	l := listen(t, New(), "tcp", "127.0.0.1:0")
+	c := dial(t, "tcp", l.Addr().String())
+
+	check(t, c, int64(4), "ZADD", "board", "10", "alice", "20", "bob", "30", "carol", "-inf", "dave")
+	check(t, c, int64(0), "ZADD", "board", "15", "alice")
+	check(t, c, "15", "ZSCORE", "board", "alice")
+	check(t, c, nil, "ZSCORE", "board", "nobody")
+	check(t, c, "-inf", "ZSCORE", "board", "dave")
+	check(t, c, "22.5", "ZINCRBY", "board", "7.5", "alice")
+	check(t, c, int64(4), "ZCARD", "board")
+	check(t, c, int64(0), "ZCARD", "nothing")
+
+	check(t, c, int64(2), "ZRANK", "board", "alice")
+	check(t, c, int64(1), "ZREVRANK", "board", "alice")
+	check(t, c, []interface{}{int64(2), "22.5"}, "ZRANK", "board", "alice", "WITHSCORE")
+	check(t, c, nil, "ZRANK", "board", "nobody")
+
+	check(t, c, []interface{}{"dave", "bob", "alice", "carol"}, "ZRANGE", "board", "0", "-1")
+	check(t, c, []interface{}{"carol", "alice"}, "ZRANGE", "board", "0", "1", "REV")
+	check(t, c, []interface{}{"bob", "20", "alice", "22.5"}, "ZRANGE", "board", "20", "(30", "BYSCORE", "WITHSCORES")
+	check(t, c, []interface{}{"carol", "alice"}, "ZRANGE", "board", "+inf", "(20", "BYSCORE", "REV")
+	check(t, c, []interface{}{"alice"}, "ZRANGE", "board", "-inf", "+inf", "BYSCORE", "LIMIT", "2", "1")
+	check(t, c, int64(3), "ZCOUNT", "board", "(-inf", "+inf")
+	check(t, c, int64(0), "ZCOUNT", "board", "30", "20")
+	check(t, c, int64(2), "ZCOUNT", "board", "20", "22.5")
+	check(t, c, int64(1), "ZCOUNT", "board", "22.5", "22.5")
+	check(t, c, int64(1), "ZCOUNT", "board", "(20", "(30")
+	check(t, c, int64(3), "ZADD", "close", "1", "a", "1", "b", "1.000001", "c")
+	check(t, c, int64(2), "ZCOUNT", "close", "1", "1")
+	check(t, c, int64(1), "ZCOUNT", "close", "1.000001", "+inf")
+	check(t, c, []interface{}{"dave"}, "ZRANGE", "board", "5", "-inf", "BYSCORE", "REV")
+	check(t, c, []interface{}{}, "ZRANGE", "board", "(-inf", "-inf", "BYSCORE", "REV")
+
+	check(t, c, int64(2), "ZREM", "board", "dave", "bob", "nobody")
+	check(t, c, []interface{}{"alice", "22.5"}, "ZPOPMIN", "board")
+	check(t, c, []interface{}{"carol", "30"}, "ZPOPMAX", "board", "5")
+	check(t, c, int64(0), "EXISTS", "board") // empty sets are deleted
+
+	check(t, c, errNotFloat, "ZADD", "board", "abc", "alice")
+	check(t, c, errors.New("ERR wrong number of arguments for 'zcard' command"), "ZCARD")
+	check(t, c, errors.New("ERR unknown command 'GET'"), "GET", "key")
+}
+
+func TestServerLex(t *testing.T) {
+	s := New()
+	s.Exec("ZADD", "words", "0", "a", "0", "b", "0", "c", "0", "d", "0", "e")
+	for _, test := range []struct {
+		args     []string
+		expected interface{}
+	}{
+		{[]string{"-", "+"}, []interface{}{"a", "b", "c", "d", "e"}},
+		{[]string{"[b", "(d"}, []interface{}{"b", "c"}},
+		{[]string{"(b", "+", "LIMIT", "1", "2"}, []interface{}{"d", "e"}},
+		{[]string{"[d", "(b", "REV"}, []interface{}{"d", "c"}},
+		{[]string{"b", "c"}, errMinMaxLex},
+	} {
+		args := append([]string{"ZRANGE", "words"}, test.args...)
+		args = append(args[:4], append([]string{"BYLEX"}, args[4:]...)...)
+		if reply := s.Exec(args...); !reflect.DeepEqual(reply, test.expected) {
+			t.Errorf("%s = %#v, expected %#v", strings.Join(args, " "), reply, test.expected)
+		}
+	}
+}
+
+func TestZAddOptions(t *testing.T) {
+	s := New()
+	s.Exec("ZADD", "z", "10", "a")
+	for _, test := range []struct {
+		args     []string
+		expected interface{}
+	}{
+		{[]string{"NX", "20", "a", "5", "b"}, int64(1)},
+		{[]string{"XX", "CH", "20", "a", "1", "c"}, int64(1)},
+		{[]string{"GT", "CH", "15", "a", "25", "b"}, int64(1)},
+		{[]string{"LT", "INCR", "5", "a"}, nil},
+		{[]string{"INCR", "-5", "a"}, float64(15)},
+		{[]string{"INCR", "1", "a", "2", "b"}, errors.New("ERR INCR option supports a single increment-element pair")},
+		{[]string{"NX", "GT", "1", "a"}, errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")},
+		{[]string{"NX", "XX", "1", "a"}, errors.New("ERR XX and NX options at the same time are not compatible")},
+		{[]string{"CH", "1"}, errSyntax},
+	} {
+		args := append([]string{"ZADD", "z"}, test.args...)
+		if reply := s.Exec(args...); !reflect.DeepEqual(reply, test.expected) {
+			t.Errorf("%s = %#v, expected %#v", strings.Join(args, " "), reply, test.expected)
+		}
+	}
+	expected := []Member{{"a", 15}, {"b", 25}}
+	if reply := s.Exec("ZRANGE", "z", "0", "-1", "WITHSCORES"); !reflect.DeepEqual(reply, expected) {
+		t.Errorf("ZRANGE = %#v, expected %#v", reply, expected)
+	}
+}
+
+func TestServerRESP3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	l := listen(t, New(), "unix", path)
+	c := dial(t, "unix", l.Addr().String())
+
+	check(t, c, nil, "ZSCORE", "board", "alice")
+	hello, ok := c.do(t, "HELLO", "3").([]interface{})
+	if !ok || len(hello) != 14 || hello[4] != "proto" || hello[5] != int64(3) {
+		t.Fatalf("HELLO 3 = %#v", hello)
+	}
+	check(t, c, int64(2), "ZADD", "board", "1.5", "alice", "inf", "bob")
+	check(t, c, 1.5, "ZSCORE", "board", "alice")
+	check(t, c, math.Inf(1), "ZSCORE", "board", "bob")
+	check(t, c, nil, "ZSCORE", "board", "nobody")
+	check(t, c, []interface{}{[]interface{}{"alice", 1.5}, []interface{}{"bob", math.Inf(1)}}, "ZRANGE", "board", "0", "-1", "WITHSCORES")
+	check(t, c, []interface{}{"alice", 1.5}, "ZPOPMIN", "board")
+	check(t, c, errors.New("NOPROTO unsupported protocol version"), "HELLO", "4")
+}
+
+func TestServerPipelineAndInline(t *testing.T) {
+	l := listen(t, New(), "tcp", "127.0.0.1:0")
+	c := dial(t, "tcp", l.Addr().String())
+
+	c.conn.Write([]byte("PING\r\nZADD board 1 alice\r\n\r\nzcard   board\n"))
+	for _, expected := range []interface{}{Status("PONG"), int64(1), int64(1)} {
+		if reply := c.read(t); !reflect.DeepEqual(reply, expected) {
+			t.Errorf("inline reply = %#v, expected %#v", reply, expected)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		c.send("ZADD", "board", strconv.Itoa(i), fmt.Sprint("player", i))
+	}
+	for i := 0; i < 100; i++ {
+		c.read(t)
+	}
+	check(t, c, int64(101), "ZCARD", "board")
+
+	check(t, c, Status("OK"), "QUIT")
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("QUIT should close the connection")
+	}
+
+	c = dial(t, "tcp", l.Addr().String())
+	c.conn.Write([]byte("*1\r\n+PING\r\n"))
+	if reply, ok := c.read(t).(error); !ok || !strings.HasPrefix(reply.Error(), "ERR Protocol error") {
+		t.Errorf("reply to a malformed command = %#v, expected a protocol error", reply)
+	}
+}
+
+func TestReadCommandLargeBulk(t *testing.T) {
+	args, err := readCommand(bufio.NewReader(strings.NewReader("*1\r\n$100000\r\n" + strings.Repeat("a", 100000) + "\r\n")))
+	if err != nil || len(args) != 1 || len(args[0]) != 100000 {
+		t.Errorf("readCommand() of a large bulk string = %d arguments, %v", len(args), err)
+	}
+
+	// the announced length is not allocated before the bytes are received
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = readCommand(bufio.NewReader(strings.NewReader("*1\r\n$536870912\r\nabc")))
+	runtime.ReadMemStats(&after)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("readCommand() of a truncated bulk string = %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("readCommand() allocated %d bytes", allocated)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	s := New()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- s.Serve(l) }()
+
+	c := dial(t, "tcp", l.Addr().String())
+	check(t, c, Status("PONG"), "PING")
+	s.Close()
+	if err := <-done; err != ErrServerClosed {
+		t.Errorf("Serve() = %v, expected ErrServerClosed", err)
+	}
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("Close should close the connections")
+	}
+}
@@ -0,0 +1,277 @@
+package sortedset
+
+import "math"
+
+// Aggregate determines how the weighted scores of an element found in several
+// sets are combined by Union and Intersect, like AGGREGATE in ZUNIONSTORE
+type Aggregate int
+
+const (
+	AggregateSum Aggregate = iota // sum of the weighted scores
+	AggregateMin                  // minimum of the weighted scores
+	AggregateMax                  // maximum of the weighted scores
+)
+
+// MergeFunc combines the Value of an element found in several sets. It is
+// called for every set after the first one containing key, in the order of
+// the sets, with the value merged so far and the value found in that set.
+// Without a MergeFunc, the value of the first set containing key is kept.
+type MergeFunc func(key string, merged interface{}, value interface{}) interface{}
+
+// Union Create a new set with the elements found in any of the sets, like
+// ZUNIONSTORE. The score of every element is the aggregate of its scores in
+// the sets it belongs to, each one multiplied by the weight of its set. A nil
+// weights means a weight of 1 for every set, otherwise it must have one
+// weight per set or Union panics. Nil sets are treated as empty sets.
+//
+//	weekly := sortedset.Union(daily, nil, sortedset.AggregateSum, nil)
+//
+// Time complexity of this method is : O(N*log(N)) with N the total number of elements
+func Union(sets []*SortedSet, weights []float64, agg Aggregate, merge MergeFunc) *SortedSet {
+	result := New()
+	result.AddOrUpdateBatch(union(sets, weights, agg, merge))
+	return result
+}
+
+// Intersect Create a new set with the elements found in every one of the
+// sets, like ZINTERSTORE. Scores and values are combined as in Union.
+//
+// Only the elements of the smallest set are looked up in the other sets.
+//
+// Time complexity of this method is : O(K*M + M*log(M)) with M the size of the smallest of K sets
+func Intersect(sets []*SortedSet, weights []float64, agg Aggregate, merge MergeFunc) *SortedSet {
+	result := New()
+	result.AddOrUpdateBatch(intersect(sets, weights, agg, merge))
+	return result
+}
+
+// UnionStore Replace the content of the set with the union of sets, see
+// Union, and return its new number of elements. The set itself may be one of
+// the sets. Options of the set are kept.
+//
+// Time complexity of this method is : O(N*log(N)) with N the total number of elements
+func (set *SortedSet) UnionStore(sets []*SortedSet, weights []float64, agg Aggregate, merge MergeFunc) int {
+	set.store(union(sets, weights, agg, merge))
+	return set.length
+}
+
+// IntersectStore Replace the content of the set with the intersection of
+// sets, see Intersect, and return its new number of elements. The set itself
+// may be one of the sets. Options of the set are kept.
+//
+// Time complexity of this method is : O(K*M + M*log(M)) with M the size of the smallest of K sets
+func (set *SortedSet) IntersectStore(sets []*SortedSet, weights []float64, agg Aggregate, merge MergeFunc) int {
+	set.store(intersect(sets, weights, agg, merge))
+	return set.length
+}
+
+// Diff Create a new set with the elements of base that are not found in any
+// of the others, like ZDIFF. Elements keep their score and value from base.
+// Nil sets are treated as empty sets.
+//
+//	churned := sortedset.Diff(lastSeason, thisSeason)
+//
+// Time complexity of this method is : O(K*N + N*log(N)) with N the size of base and K the number of others
+func Diff(base *SortedSet, others ...*SortedSet) *SortedSet {
+	result := New()
+	result.AddOrUpdateBatch(diff(base, others))
+	return result
+}
+
+// SymmetricDiff Create a new set with the elements found in exactly one of
+// the sets, keeping their score and value. For two sets, these are the
+// elements of either set that are not in the other one.
+//
+// Time complexity of this method is : O(K*N + N*log(N)) with N the total number of elements of K sets
+func SymmetricDiff(sets ...*SortedSet) *SortedSet {
+	result := New()
+	result.AddOrUpdateBatch(symmetricDiff(sets))
+	return result
+}
+
+// DiffStore Replace the content of the set with the difference between base
+// and others, see Diff, and return its new number of elements. The set itself
+// may be base or one of the others. Options of the set are kept.
+//
+// Time complexity of this method is : O(K*N + N*log(N)) with N the size of base and K the number of others
+func (set *SortedSet) DiffStore(base *SortedSet, others ...*SortedSet) int {
+	set.store(diff(base, others))
+	return set.length
+}
+
+// SymmetricDiffStore Replace the content of the set with the elements found
+// in exactly one of the sets, see SymmetricDiff, and return its new number of
+// elements. The set itself may be one of the sets. Options of the set are kept.
+//
+// Time complexity of this method is : O(K*N + N*log(N)) with N the total number of elements of K sets
+func (set *SortedSet) SymmetricDiffStore(sets ...*SortedSet) int {
+	set.store(symmetricDiff(sets))
+	return set.length
+}
+
+/* Replace the content of the set with entries, notifying listeners like
+ * UnmarshalBinary does. Elements over the maximum size are evicted from the
+ * set once its content is replaced. */
+func (set *SortedSet) store(entries []Entry) {
+	loaded := *set
+	loaded.reset()
+	loaded.tx = nil
+	loaded.listeners = nil
+	loaded.indexes = nil
+	loaded.maxSize = 0
+	loaded.AddOrUpdateBatch(entries)
+	loaded.tx = set.tx
+	loaded.listeners = set.listeners
+	loaded.indexes = set.indexes
+	loaded.maxSize = set.maxSize
+	set.replaceWith(&loaded)
+	set.evictOverflow()
+}
+
+func union(sets []*SortedSet, weights []float64, agg Aggregate, merge MergeFunc) []Entry {
+	checkWeights(sets, weights)
+	index := make(map[string]int)
+	var entries []Entry
+	for i, s := range sets {
+		if s == nil {
+			continue
+		}
+		s.evictExpired()
+		for x := s.header.level[0].forward; x != nil; x = x.level[0].forward {
+			score := weighted(x.score, weights, i)
+			j, found := index[x.key]
+			if !found {
+				index[x.key] = len(entries)
+				entries = append(entries, Entry{Key: x.key, Score: score, Value: x.Value})
+				continue
+			}
+			entry := &entries[j]
+			entry.Score = aggregate(agg, entry.Score, score)
+			if merge != nil {
+				entry.Value = merge(x.key, entry.Value, x.Value)
+			}
+		}
+	}
+	return entries
+}
+
+func intersect(sets []*SortedSet, weights []float64, agg Aggregate, merge MergeFunc) []Entry {
+	checkWeights(sets, weights)
+	var smallest *SortedSet
+	for _, s := range sets {
+		if s == nil {
+			return nil
+		}
+		s.evictExpired()
+		if smallest == nil || s.length < smallest.length {
+			smallest = s
+		}
+	}
+	if smallest == nil {
+		return nil
+	}
+
+	var entries []Entry
+next:
+	for key := range smallest.dict {
+		var entry Entry
+		for i, s := range sets {
+			x := s.dict[key]
+			if x == nil {
+				continue next
+			}
+			score := weighted(x.score, weights, i)
+			if i == 0 {
+				entry = Entry{Key: key, Score: score, Value: x.Value}
+				continue
+			}
+			entry.Score = aggregate(agg, entry.Score, score)
+			if merge != nil {
+				entry.Value = merge(key, entry.Value, x.Value)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+/* Elements of base missing from every other set, in the order of base. */
+func diff(base *SortedSet, others []*SortedSet) []Entry {
+	if base == nil {
+		return nil
+	}
+	base.evictExpired()
+	for _, s := range others {
+		if s != nil {
+			s.evictExpired()
+		}
+	}
+	var entries []Entry
+	for x := base.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if !containedIn(x.key, others, -1) {
+			entries = append(entries, Entry{Key: x.key, Score: x.score, Value: x.Value})
+		}
+	}
+	return entries
+}
+
+func symmetricDiff(sets []*SortedSet) []Entry {
+	for _, s := range sets {
+		if s != nil {
+			s.evictExpired()
+		}
+	}
+	var entries []Entry
+	for i, s := range sets {
+		if s == nil {
+			continue
+		}
+		for x := s.header.level[0].forward; x != nil; x = x.level[0].forward {
+			if !containedIn(x.key, sets, i) {
+				entries = append(entries, Entry{Key: x.key, Score: x.score, Value: x.Value})
+			}
+		}
+	}
+	return entries
+}
+
+/* Whether key is in one of the sets, except the one at index except. */
+func containedIn(key string, sets []*SortedSet, except int) bool {
+	for i, s := range sets {
+		if i != except && s != nil && s.dict[key] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func checkWeights(sets []*SortedSet, weights []float64) {
+	if weights != nil && len(weights) != len(sets) {
+		panic("sortedset: the number of weights does not match the number of sets")
+	}
+}
+
+/* Score multiplied by the weight of the i-th set, 0 * ±Inf being 0 like in Redis. */
+func weighted(score float64, weights []float64, i int) float64 {
+	if weights == nil {
+		return score
+	}
+	if score = score * weights[i]; math.IsNaN(score) {
+		return 0
+	}
+	return score
+}
+
+/* Combine two scores, +Inf + -Inf being 0 like in Redis. */
+func aggregate(agg Aggregate, a, b float64) float64 {
+	switch agg {
+	case AggregateMin:
+		return math.Min(a, b)
+	case AggregateMax:
+		return math.Max(a, b)
+	}
+	if sum := a + b; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
@@ -0,0 +1,222 @@
+package sortedset
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/* Check the keys and scores of a set, given in rank order. */
+func checkScores(t *testing.T, set *SortedSet, keys []string, scores []float64) {
+	t.Helper()
+	checkOrder(t, set.GetByRankRange(1, -1, false), keys)
+	for i, key := range keys {
+		if node := set.GetByKey(key); node == nil || node.Score() != scores[i] {
+			t.Errorf("score of %q is %v, expected %v", key, node, scores[i])
+		}
+	}
+	checkRanks(t, set)
+}
+
+func TestUnion(t *testing.T) {
+	a, b, c := New(), New(), New()
+	a.AddOrUpdate("x", 1, "a")
+	a.AddOrUpdate("y", 2, "a")
+	b.AddOrUpdate("y", 3, "b")
+	b.AddOrUpdate("z", 4, "b")
+	c.AddOrUpdate("x", 10, "c")
+
+	sets := []*SortedSet{a, b, nil, c}
+	checkScores(t, Union(sets, nil, AggregateSum, nil), []string{"z", "y", "x"}, []float64{4, 5, 11})
+	checkScores(t, Union(sets, nil, AggregateMin, nil), []string{"x", "y", "z"}, []float64{1, 2, 4})
+	checkScores(t, Union(sets, nil, AggregateMax, nil), []string{"y", "z", "x"}, []float64{3, 4, 10})
+	checkScores(t, Union(sets, []float64{2, -1, 5, 0}, AggregateSum, nil), []string{"z", "y", "x"}, []float64{-4, 1, 2})
+
+	if value := Union(sets, nil, AggregateSum, nil).GetByKey("x").Value; value != "a" {
+		t.Errorf("value of x is %v, expected the value of the first set", value)
+	}
+	concat := func(key string, merged, value interface{}) interface{} {
+		return fmt.Sprint(merged, "+", value)
+	}
+	if value := Union(sets, nil, AggregateSum, concat).GetByKey("x").Value; value != "a+c" {
+		t.Errorf("merged value of x is %v, expected a+c", value)
+	}
+
+	// 0 * inf and inf - inf are 0 like in Redis
+	a.AddOrUpdate("inf", math.Inf(1), nil)
+	b.AddOrUpdate("inf", math.Inf(-1), nil)
+	if score := Union([]*SortedSet{a, b}, []float64{1, 1}, AggregateSum, nil).GetByKey("inf").Score(); score != 0 {
+		t.Errorf("score of inf + -inf is %v, expected 0", score)
+	}
+	if score := Union([]*SortedSet{a}, []float64{0}, AggregateSum, nil).GetByKey("inf").Score(); score != 0 {
+		t.Errorf("score of 0 * inf is %v, expected 0", score)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Union() should panic when the weights do not match the sets")
+		}
+	}()
+	Union(sets, []float64{1}, AggregateSum, nil)
+}
+
+func TestIntersect(t *testing.T) {
+	a, b, c := New(), New(), New()
+	for i := 0; i < 100; i++ {
+		a.AddOrUpdate(fmt.Sprint(i), float64(i), i)
+		if i%2 == 0 {
+			b.AddOrUpdate(fmt.Sprint(i), 1, i*10)
+		}
+		if i%3 == 0 {
+			c.AddOrUpdate(fmt.Sprint(i), 2, nil)
+		}
+	}
+
+	result := Intersect([]*SortedSet{a, b, c}, []float64{1, 10, 100}, AggregateSum, nil)
+	if result.GetCount() != 17 {
+		t.Errorf("intersection has %d elements, expected 17", result.GetCount())
+	}
+	for x := result.PeekMin(); x != nil; x = x.Next() {
+		i := a.GetByKey(x.Key()).Value.(int)
+		if i%6 != 0 || x.Score() != float64(i)+210 || x.Value != i {
+			t.Errorf("element %q %v %v does not belong to the intersection", x.Key(), x.Score(), x.Value)
+		}
+	}
+	checkRanks(t, result)
+
+	result = Intersect([]*SortedSet{b, a}, nil, AggregateMax, func(key string, merged, value interface{}) interface{} {
+		return merged.(int) + value.(int)
+	})
+	if node := result.GetByKey("10"); node == nil || node.Score() != 10 || node.Value != 110 {
+		t.Errorf("element 10 of the intersection is %v, expected score 10 and value 110", node)
+	}
+
+	if result := Intersect([]*SortedSet{a, nil}, nil, AggregateSum, nil); result.GetCount() != 0 {
+		t.Error("intersection with a nil set should be empty")
+	}
+	if result := Intersect(nil, nil, AggregateSum, nil); result.GetCount() != 0 {
+		t.Error("intersection of no set should be empty")
+	}
+}
+
+func TestUnionStore(t *testing.T) {
+	a, b := New(), New()
+	a.AddOrUpdate("x", 1, nil)
+	a.AddOrUpdate("y", 2, nil)
+	b.AddOrUpdate("y", 3, nil)
+
+	var changes []string
+	a.listen(func(e Event) {
+		changes = append(changes, e.Key)
+	})
+	if n := a.UnionStore([]*SortedSet{a, b}, nil, AggregateSum, nil); n != 2 {
+		t.Errorf("UnionStore() = %d, expected 2", n)
+	}
+	checkScores(t, a, []string{"x", "y"}, []float64{1, 5})
+	if len(changes) != 4 {
+		t.Errorf("listeners were notified of %v, expected the removal and addition of x and y", changes)
+	}
+
+	if n := a.IntersectStore([]*SortedSet{a, b}, []float64{1, 2}, AggregateMin, nil); n != 1 {
+		t.Errorf("IntersectStore() = %d, expected 1", n)
+	}
+	checkScores(t, a, []string{"y"}, []float64{5})
+}
+
+func TestStoreCapped(t *testing.T) {
+	var set *SortedSet
+	var evicted []string
+	set = New(WithMaxSize(2, EvictMax), WithEvictCallback(func(node *Node) {
+		if set.GetByKey("old") != nil {
+			t.Errorf("%q was evicted before the content of the set was replaced", node.Key())
+		}
+		evicted = append(evicted, node.Key())
+	}))
+	set.AddOrUpdate("old", 0, nil)
+	other := New()
+	for i, key := range []string{"d", "c", "b", "a"} {
+		other.AddOrUpdate(key, float64(4-i), nil)
+	}
+
+	if n := set.UnionStore([]*SortedSet{other}, nil, AggregateSum, nil); n != 2 {
+		t.Errorf("UnionStore() = %d, expected 2", n)
+	}
+	checkScores(t, set, []string{"a", "b"}, []float64{1, 2})
+	if len(evicted) != 2 || evicted[0] != "d" || evicted[1] != "c" {
+		t.Errorf("evicted %v, expected d and c", evicted)
+	}
+}
+
+func TestStoreRollback(t *testing.T) {
+	set, c := New(), New()
+	for i := 0; i < 50; i++ {
+		set.AddOrUpdate(fmt.Sprint("key", i), float64(i%7), i)
+	}
+	set.Expire("key1", time.Hour)
+	c.AddOrUpdate("c", 3, nil)
+	c.AddOrUpdate("key2", 100, nil)
+	before := skiplistByKey(set) // the header is replaced
+	data, _ := set.MarshalBinary()
+	node := set.GetByKey("key0")
+
+	tx := set.Begin()
+	set.AddOrUpdate("key3", 50, nil) // journaled before the replacement
+	set.Remove("key4")
+	set.UnionStore([]*SortedSet{c}, nil, AggregateSum, nil)
+	set.AddOrUpdate("c", 4, nil) // journaled after the replacement
+	set.DiffStore(set, c)
+	set.UnmarshalBinary(data)
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(skiplistByKey(set), before) {
+		t.Error("Rollback() should link the original nodes back with their levels")
+	}
+	checkRanks(t, set)
+	if set.GetByKey("key0") != node {
+		t.Error("Rollback() should restore the original node of key0")
+	}
+	if set.GetCount() != 50 || set.GetByKey("c") != nil {
+		t.Errorf("Rollback() should undo the replacements, the set holds %d elements", set.GetCount())
+	}
+	if _, ok := set.TTL("key1"); !ok {
+		t.Error("Rollback() should restore the TTL of key1")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	last, this, banned := New(), New(), New()
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		last.AddOrUpdate(key, float64(10-i), i)
+	}
+	this.AddOrUpdate("b", 100, nil)
+	this.AddOrUpdate("f", 50, nil)
+	banned.AddOrUpdate("d", 0, nil)
+
+	result := Diff(last, this, nil, banned)
+	checkScores(t, result, []string{"e", "c", "a"}, []float64{6, 8, 10})
+	if value := result.GetByKey("c").Value; value != 2 {
+		t.Errorf("value of c is %v, expected the value in base", value)
+	}
+	if Diff(last, last).GetCount() != 0 || Diff(nil, this).GetCount() != 0 {
+		t.Error("difference with itself or of a nil set should be empty")
+	}
+	checkScores(t, Diff(this), []string{"f", "b"}, []float64{50, 100})
+
+	checkScores(t, SymmetricDiff(last, this), []string{"e", "d", "c", "a", "f"}, []float64{6, 7, 8, 10, 50})
+	if SymmetricDiff(this, this).GetCount() != 0 {
+		t.Error("symmetric difference of a set with itself should be empty")
+	}
+
+	if n := last.DiffStore(last, this, banned); n != 3 {
+		t.Errorf("DiffStore() = %d, expected 3", n)
+	}
+	checkScores(t, last, []string{"e", "c", "a"}, []float64{6, 8, 10})
+	if n := this.SymmetricDiffStore(last, this); n != 5 {
+		t.Errorf("SymmetricDiffStore() = %d, expected 5", n)
+	}
+	checkScores(t, this, []string{"e", "c", "a", "f", "b"}, []float64{6, 8, 10, 50, 100})
+}
@@ -0,0 +1,156 @@
+package sortedset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+const (
+	snapshotMagic       = "SSNP"
+	snapshotVersion     = 1
+	snapshotHeaderSize  = len(snapshotMagic) + 1 + 8 // magic, version and payload length as uint64
+	snapshotTrailerSize = 4                          // CRC32-C of the payload
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrSnapshotTruncated is the reason of a SnapshotError for a file shorter than written
+	ErrSnapshotTruncated = errors.New("snapshot is truncated")
+	// ErrSnapshotChecksum is the reason of a SnapshotError for a file whose content does not match its checksum
+	ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+)
+
+// SnapshotError is returned by LoadSnapshot when the file is not a valid
+// snapshot. Err is ErrSnapshotTruncated, ErrSnapshotChecksum, ErrInvalidData,
+// ErrUnsupportedVersion or an error of the ValueCodec, and can be tested with errors.Is.
+type SnapshotError struct {
+	Path string
+	Err  error
+}
+
+func (e *SnapshotError) Error() string {
+	return fmt.Sprintf("sortedset: load snapshot %s: %v", e.Path, e.Err)
+}
+
+func (e *SnapshotError) Unwrap() error {
+	return e.Err
+}
+
+// SaveSnapshot write the whole set to the file at path.
+//
+// The snapshot is written to a temporary file in the same directory, flushed
+// to stable storage and renamed over path, so path always holds either the
+// previous or the new snapshot, even after a crash. The content is the one of
+// MarshalBinary, preceded by its length and followed by its checksum, so a
+// truncated file is always detected. The file keeps the permissions of the
+// snapshot it replaces, a new one is readable by everyone.
+//
+// Time complexity of this method is : O(N)
+func (set *SortedSet) SaveSnapshot(path string) error {
+	payload, err := set.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 0, snapshotHeaderSize+len(payload)+snapshotTrailerSize)
+	data = append(data, snapshotMagic...)
+	data = append(data, snapshotVersion)
+	data = binary.BigEndian.AppendUint64(data, uint64(len(payload)))
+	data = append(data, payload...)
+	data = binary.BigEndian.AppendUint32(data, crc32.Checksum(payload, crc32c))
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	err = tmp.Chmod(mode)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+/* Make a rename in dir durable, which is not supported by every platform. */
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// LoadSnapshot Create a new SortedSet with the given options from a file
+// written by SaveSnapshot. A *SnapshotError is returned if the file is
+// truncated or corrupted, in which case no set is returned.
+//
+// Time complexity of this method is : O(N)
+func LoadSnapshot(path string, options ...Option) (*SortedSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (*SortedSet, error) {
+		return nil, &SnapshotError{Path: path, Err: err}
+	}
+
+	magic := data
+	if len(magic) > len(snapshotMagic) {
+		magic = magic[:len(snapshotMagic)]
+	}
+	if string(magic) != snapshotMagic[:len(magic)] {
+		return fail(ErrInvalidData)
+	}
+	if len(data) > len(snapshotMagic) {
+		if version := data[len(snapshotMagic)]; version != snapshotVersion {
+			return fail(fmt.Errorf("%w: %d", ErrUnsupportedVersion, version))
+		}
+	}
+	if len(data) < snapshotHeaderSize {
+		return fail(ErrSnapshotTruncated)
+	}
+
+	length := binary.BigEndian.Uint64(data[len(snapshotMagic)+1:])
+	rest := uint64(len(data) - snapshotHeaderSize)
+	if rest < snapshotTrailerSize || rest-snapshotTrailerSize < length {
+		return fail(ErrSnapshotTruncated)
+	}
+	if rest-snapshotTrailerSize > length {
+		return fail(ErrInvalidData)
+	}
+	payload := data[snapshotHeaderSize : len(data)-snapshotTrailerSize]
+	if crc32.Checksum(payload, crc32c) != binary.BigEndian.Uint32(data[len(data)-snapshotTrailerSize:]) {
+		return fail(ErrSnapshotChecksum)
+	}
+
+	set := New(options...)
+	if err := set.UnmarshalBinary(payload); err != nil {
+		return fail(err)
+	}
+	return set, nil
+}
@@ -0,0 +1,100 @@
+package sortedset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.snapshot")
+	sortedset := New()
+	for i := 0; i < 100; i++ {
+		sortedset.AddOrUpdate(fmt.Sprint(i), float64(i%7), fmt.Sprint("value", i))
+	}
+	if err := sortedset.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+		t.Errorf("a new snapshot has mode %v, expected %v", info.Mode().Perm(), os.FileMode(0644))
+	}
+
+	// saving again replaces the file, keeping its mode
+	os.Chmod(path, 0640)
+	sortedset.Remove("0")
+	if err := sortedset.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("a replaced snapshot has mode %v, expected %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("SaveSnapshot() should not leave temporary files, found %d files", len(entries))
+	}
+
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameNodes(t, loaded, sortedset)
+	checkRanks(t, loaded)
+}
+
+func TestLoadSnapshotErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "set.snapshot")
+	sortedset := New()
+	sortedset.AddOrUpdate("a", 1, "Alice")
+	sortedset.AddOrUpdate("b", 2, "Bob")
+	if err := sortedset.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+
+	for _, test := range []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"checksum", append(append([]byte{}, data[:20]...), append([]byte{data[20] ^ 1}, data[21:]...)...), ErrSnapshotChecksum},
+		{"trailing data", append(append([]byte{}, data...), 0), ErrInvalidData},
+		{"version", append(append([]byte{}, data[:4]...), 9), ErrUnsupportedVersion},
+		{"not a snapshot", []byte("hello world, this is not a snapshot"), ErrInvalidData},
+	} {
+		corrupted := filepath.Join(dir, test.name)
+		os.WriteFile(corrupted, test.data, 0644)
+		set, err := LoadSnapshot(corrupted)
+		var snapshotErr *SnapshotError
+		if set != nil || !errors.As(err, &snapshotErr) || !errors.Is(err, test.expected) {
+			t.Errorf("%s: LoadSnapshot() returns %v, %v, expected %v", test.name, set, err, test.expected)
+		}
+	}
+
+	if _, err := LoadSnapshot(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadSnapshot() of a missing file returns %v", err)
+	}
+}
+
+func TestLoadSnapshotTruncated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "set.snapshot")
+	sortedset := New()
+	for i := 0; i < 100; i++ {
+		sortedset.AddOrUpdate(fmt.Sprint(i), float64(i), fmt.Sprint("value", i))
+	}
+	if err := sortedset.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+
+	// every cut is detected, whatever the bytes left at the end of the file
+	for n := 0; n < len(data); n++ {
+		os.WriteFile(path, data[:n], 0644)
+		if _, err := LoadSnapshot(path); !errors.Is(err, ErrSnapshotTruncated) {
+			t.Fatalf("LoadSnapshot() of the first %d of %d bytes returns %v, expected ErrSnapshotTruncated", n, len(data), err)
+		}
+	}
+}
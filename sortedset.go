@@ -7,12 +7,34 @@ import (
 )
 
 type SortedSet struct {
-	header *Node
-	tail   *Node
-	length int
-	level  int
-	dict   map[string]*Node
-	r      *rand.Rand
+	header  *Node
+	tail    *Node
+	length  int
+	level   int
+	dict    map[string]*Node
+	r       *rand.Rand
+	tx      *Tx              // transaction in progress, nil if none
+	version uint64           // number of modifications made to the set
+	expires *expiryQueue     // deadlines of the nodes having a TTL, created on first use
+	now     func() time.Time // clock used for lazy expiry
+
+	maxSize     int         // maximum number of elements, 0 if unlimited
+	evictPolicy EvictPolicy // which element is evicted when the set is full
+	onEvict     func(node *Node)
+	codec       ValueCodec // encodes values in MarshalBinary, GobCodec if nil
+
+	digest    uint64              // sum of the hashes of the elements, see Digest
+	listeners []*listener         // notified after every change of a key
+	pending   map[string]previous // state of the keys being changed, only kept for listeners
+	evicting  bool                // removals are evictions
+	expiring  int64               // deadline given to the element put by AddOrUpdateWithTTL, 0 to keep its own
+	indexes   map[string]*index   // secondary indexes by name, see AddIndex
+}
+
+// listener is notified with the event of every change of a key, see Subscribe
+type listener struct {
+	fn     func(e Event)
+	expiry bool // also notified of the changes of TTLs, see notifyExpiry
 }
 
 func createNode(level int, score float64, key string, value interface{}) *Node {
@@ -41,11 +63,30 @@ func (set *SortedSet) randomLevel() int {
 	return SkiplistMaxLevel
 }
 
-func (set *SortedSet) insertNode(score float64, key string, value interface{}) *Node {
+/* The update vector of the last insertion, it lets a sequence of insertions
+ * in ascending order start searching from the previous position instead of
+ * from the header. It is only valid as long as no node is deleted. */
+type finger struct {
+	update [SkiplistMaxLevel]*Node
+	rank   [SkiplistMaxLevel]int
+}
+
+func (set *SortedSet) insertNode(f *finger, score float64, key string, value interface{}) *Node {
+	x := createNode(set.randomLevel(), score, key, value)
+	set.linkNode(x, f)
+	return x
+}
+
+/* Link a detached node into the skiplist, keeping the level it was created
+ * with. Linking a node that was previously unlinked restores exactly the
+ * structure it had before. If f is not nil, the search starts from it and it
+ * is updated to the position of x. */
+func (set *SortedSet) linkNode(x *Node, f *finger) {
 	var update [SkiplistMaxLevel]*Node
 	var rank [SkiplistMaxLevel]int
 
-	x := set.header
+	score, key := x.score, x.key
+	n := set.header
 	for i := set.level - 1; i >= 0; i-- {
 		/* store rank that is crossed to reach the insert position */
 		if set.level-1 == i {
@@ -53,22 +94,27 @@ func (set *SortedSet) insertNode(score float64, key string, value interface{}) *
 		} else {
 			rank[i] = rank[i+1]
 		}
+		/* jump to the finger if it is ahead of the current node */
+		if f != nil && f.rank[i] > rank[i] {
+			n = f.update[i]
+			rank[i] = f.rank[i]
+		}
 
-		for x.level[i].forward != nil &&
-			(x.level[i].forward.score < score ||
-				(math.Abs(x.level[i].forward.score-score) < eps && // score is the same but the key is different
-					x.level[i].forward.key < key)) {
-			rank[i] += x.level[i].span
-			x = x.level[i].forward
+		for n.level[i].forward != nil &&
+			(n.level[i].forward.score < score ||
+				(sameScore(n.level[i].forward.score, score) && // score is the same but the key is different
+					n.level[i].forward.key < key)) {
+			rank[i] += n.level[i].span
+			n = n.level[i].forward
 		}
-		update[i] = x
+		update[i] = n
 	}
 
 	/* we assume the key is not already inside, since we allow duplicated
 	 * scores, and the re-insertion of score and redis object should never
 	 * happen since the caller of Insert() should test in the hash table
 	 * if the element is already inside or not. */
-	level := set.randomLevel()
+	level := len(x.level)
 
 	if level > set.level { // add a new level
 		for i := set.level; i < level; i++ {
@@ -79,7 +125,6 @@ func (set *SortedSet) insertNode(score float64, key string, value interface{}) *
 		set.level = level
 	}
 
-	x = createNode(level, score, key, value)
 	for i := 0; i < level; i++ {
 		x.level[i].forward = update[i].level[i].forward
 		update[i].level[i].forward = x
@@ -105,7 +150,17 @@ func (set *SortedSet) insertNode(score float64, key string, value interface{}) *
 		set.tail = x
 	}
 	set.length++
-	return x
+	set.digest += elementHash(x.key, x.score)
+
+	if f != nil {
+		for i := 0; i < set.level; i++ {
+			if i < level {
+				f.update[i], f.rank[i] = x, rank[0]+1
+			} else {
+				f.update[i], f.rank[i] = update[i], rank[i]
+			}
+		}
+	}
 }
 
 /* Internal function used by delete, DeleteByScore and DeleteByRank */
@@ -127,6 +182,7 @@ func (set *SortedSet) deleteNode(x *Node, update [SkiplistMaxLevel]*Node) {
 		set.level--
 	}
 	set.length--
+	set.digest -= elementHash(x.key, x.score)
 	delete(set.dict, x.key)
 }
 
@@ -138,7 +194,7 @@ func (set *SortedSet) delete(score float64, key string) bool {
 	for i := set.level - 1; i >= 0; i-- {
 		for x.level[i].forward != nil &&
 			(x.level[i].forward.score < score ||
-				(math.Abs(x.level[i].forward.score-score) < eps &&
+				(sameScore(x.level[i].forward.score, score) &&
 					x.level[i].forward.key < key)) {
 			x = x.level[i].forward
 		}
@@ -147,7 +203,7 @@ func (set *SortedSet) delete(score float64, key string) bool {
 	/* We may have multiple elements with the same score, what we need
 	 * is to find the element with both the right score and object. */
 	x = x.level[0].forward
-	if x != nil && math.Abs(score-x.score) < eps && x.key == key {
+	if x != nil && sameScore(score, x.score) && x.key == key {
 		set.deleteNode(x, update)
 		// free x
 		return true
@@ -156,18 +212,23 @@ func (set *SortedSet) delete(score float64, key string) bool {
 }
 
 // New Create a new SortedSet
-func New() *SortedSet {
+func New(options ...Option) *SortedSet {
 	sortedSet := SortedSet{
 		header: createNode(SkiplistMaxLevel, math.Inf(-1), "", nil),
 		level:  1,
 		dict:   make(map[string]*Node),
 		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:    time.Now,
+	}
+	for _, option := range options {
+		option(&sortedSet)
 	}
 	return &sortedSet
 }
 
 // GetCount Get the number of elements
 func (set *SortedSet) GetCount() int {
+	set.evictExpired()
 	return set.length
 }
 
@@ -175,6 +236,7 @@ func (set *SortedSet) GetCount() int {
 //
 // Time complexity of this method is : O(1)
 func (set *SortedSet) PeekMin() *Node {
+	set.evictExpired()
 	return set.header.level[0].forward
 }
 
@@ -182,6 +244,7 @@ func (set *SortedSet) PeekMin() *Node {
 //
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) PopMin() *Node {
+	set.evictExpired()
 	x := set.header.level[0].forward
 	if x != nil {
 		set.Remove(x.key)
@@ -193,6 +256,7 @@ func (set *SortedSet) PopMin() *Node {
 //
 // Time Complexity : O(1)
 func (set *SortedSet) PeekMax() *Node {
+	set.evictExpired()
 	return set.tail
 }
 
@@ -200,6 +264,7 @@ func (set *SortedSet) PeekMax() *Node {
 //
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) PopMax() *Node {
+	set.evictExpired()
 	x := set.tail
 	if x != nil {
 		set.Remove(x.key)
@@ -210,41 +275,225 @@ func (set *SortedSet) PopMax() *Node {
 // AddOrUpdate Add an element into the sorted set with specific key / value / score.
 // if the element is added, this method returns true; otherwise false means updated
 //
+// If the set is created with WithMaxSize and is full, adding an element evicts
+// the element with minimum or maximum score according to the policy. An
+// element which would be evicted right away is not added and false is returned.
+//
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) AddOrUpdate(key string, score float64, value interface{}) bool {
-	var newNode *Node = nil
+	set.evictExpired()
+	_, added := set.put(key, score, value)
+	return added
+}
 
+/* Internal function shared by every method adding or updating a key. It
+ * returns the node now holding the key and whether the key was added. The
+ * node is nil if the key was rejected because the set is full. */
+func (set *SortedSet) put(key string, score float64, value interface{}) (*Node, bool) {
 	found := set.dict[key]
+	if found == nil && set.isFull() && set.wouldEvict(score, key) {
+		return nil, false
+	}
 	if found != nil {
+		set.beforeChange(key, found)
 		// score does not change, only update value
-		if math.Abs(found.score-score) < eps {
+		if sameScore(found.score, score) {
 			found.Value = value
-		} else { // score changes, delete and re-insert
-			set.delete(found.score, found.key)
-			newNode = set.insertNode(score, key, value)
+			found.version = set.version
+			if set.expiring != 0 {
+				found.expireAt = set.expiring
+			}
+			set.afterChange(key, found)
+			return found, false
 		}
+		// score changes, delete and re-insert
+		set.delete(found.score, found.key)
 	} else {
-		newNode = set.insertNode(score, key, value)
+		set.beforeChange(key, nil)
 	}
 
-	if newNode != nil {
-		set.dict[key] = newNode
+	newNode := set.insertReplacing(nil, found, key, score, value)
+	if found == nil {
+		set.evictOverflow()
 	}
-	return found == nil
+	return newNode, found == nil
+}
+
+/* Insert a new node for key, carrying over the TTL of the node it replaces,
+ * if any. The replaced node must already be deleted. */
+func (set *SortedSet) insertReplacing(f *finger, replaced *Node, key string, score float64, value interface{}) *Node {
+	newNode := set.insertNode(f, score, key, value)
+	newNode.version = set.version
+	if replaced != nil {
+		newNode.expireAt = replaced.expireAt
+	}
+	if set.expiring != 0 {
+		newNode.expireAt = set.expiring
+	}
+	set.dict[key] = newNode
+	set.afterChange(key, newNode)
+	return newNode
+}
+
+// IncrBy Increment the score of the element specified by key by delta and return the new score.
+// If the element does not exist, it is added with delta as its score and a nil value
+//
+// Time complexity of this method is : O(log(N))
+func (set *SortedSet) IncrBy(key string, delta float64) float64 {
+	set.evictExpired()
+	var value interface{}
+	score := delta
+	if found := set.dict[key]; found != nil {
+		score += found.score
+		value = found.Value
+	}
+	set.put(key, score, value)
+	return score
+}
+
+// CompareAndSet Add or update the element specified by key only if its current version
+// equals expectedVersion; an expectedVersion of 0 means the element must not exist.
+// It returns true if the element was written. Versions of a key are never
+// reused, so a version read before the key was removed and added again does
+// not match.
+//
+// Together with Version(), it allows callers to implement optimistic concurrency
+// similar to WATCH/MULTI in Redis.
+//
+// Time complexity of this method is : O(log(N))
+func (set *SortedSet) CompareAndSet(key string, expectedVersion uint64, score float64, value interface{}) bool {
+	set.evictExpired()
+	var version uint64
+	if found := set.dict[key]; found != nil {
+		version = found.version
+	}
+	if version != expectedVersion {
+		return false
+	}
+	node, _ := set.put(key, score, value)
+	return node != nil
+}
+
+// Version Get the modification counter of the set, which is incremented by every change
+func (set *SortedSet) Version() uint64 {
+	return set.version
 }
 
 // Remove Delete element specified by key
 //
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) Remove(key string) *Node {
+	set.evictExpired()
 	found := set.dict[key]
 	if found != nil {
-		set.delete(found.score, found.key)
+		set.removeNode(found)
 		return found
 	}
 	return nil
 }
 
+/* Internal function shared by every method removing a node by key. */
+func (set *SortedSet) removeNode(x *Node) {
+	set.beforeChange(x.key, x)
+	set.delete(x.score, x.key)
+	set.afterRemove(x)
+}
+
+/* Called right before key, currently held by node (nil if absent), is changed. */
+func (set *SortedSet) beforeChange(key string, node *Node) {
+	set.version++
+	set.record(key, node)
+	set.remember(key, node)
+}
+
+/* Called right after node x has been removed from the set. */
+func (set *SortedSet) afterRemove(x *Node) {
+	x.version = set.version
+	if x.expireAt != 0 {
+		set.expires.remove(x.key)
+	}
+	set.afterChange(x.key, nil)
+}
+
+/* Called right after key has changed, node being the node now holding it or
+ * nil if it was removed. */
+func (set *SortedSet) afterChange(key string, node *Node) {
+	set.reindex(key, node)
+	if len(set.listeners) == 0 {
+		return
+	}
+	set.notify(set.event(key, node))
+}
+
+func (set *SortedSet) notify(e Event) {
+	for _, l := range set.listeners {
+		l.fn(e)
+	}
+}
+
+/* Tell the listeners interested in TTLs that only the deadline of node
+ * changed. It is not an event for subscribers, the event is only sent to
+ * listeners logging the state of the keys, like WAL and Leader. */
+func (set *SortedSet) notifyExpiry(node *Node) {
+	for _, l := range set.listeners {
+		if l.expiry {
+			l.fn(Event{Type: EventValueChanged, Key: node.key, Score: node.score, OldScore: node.score, Value: node.Value, OldValue: node.Value, Node: node})
+		}
+	}
+}
+
+/* Register fn to be notified after every change of a key. */
+func (set *SortedSet) listen(fn func(e Event)) *listener {
+	l := &listener{fn: fn}
+	set.listeners = append(set.listeners, l)
+	return l
+}
+
+/* Stop notifying a listener registered by listen. */
+func (set *SortedSet) unlisten(l *listener) {
+	for i, registered := range set.listeners {
+		if registered == l {
+			set.listeners = append(set.listeners[:i:i], set.listeners[i+1:]...)
+			if len(set.listeners) == 0 {
+				set.pending = nil
+			}
+			return
+		}
+	}
+}
+
+/* Replace the content of the set by the one of loaded, a copy of the set
+ * filled without notifying listeners nor updating indexes. Like for
+ * listeners, the transaction in progress journals the removal of every old
+ * key then the addition of every new key, so Rollback links the old nodes
+ * back. Indexes are rebuilt. */
+func (set *SortedSet) replaceWith(loaded *SortedSet) {
+	old := set.header
+	if set.tx != nil {
+		for x := old.level[0].forward; x != nil; x = x.level[0].forward {
+			set.record(x.key, x)
+		}
+		for x := loaded.header.level[0].forward; x != nil; x = x.level[0].forward {
+			set.record(x.key, nil)
+		}
+	}
+	*set = *loaded
+	set.rebuildIndexes()
+	if len(set.listeners) == 0 {
+		return
+	}
+	set.pending = nil
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		set.remember(x.key, nil)
+	}
+	for x := old.level[0].forward; x != nil; x = x.level[0].forward {
+		set.notify(Event{Type: EventRemoved, Key: x.key, Score: x.score, OldScore: x.score, Value: x.Value, OldValue: x.Value})
+	}
+	for x := set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		set.afterChange(x.key, x)
+	}
+}
+
 type GetByScoreRangeOptions struct {
 	Limit        int  // limit the max nodes to return
 	ExcludeStart bool // exclude start value, so it search in interval (start, end] or (start, end)
@@ -257,7 +506,7 @@ type GetByScoreRangeOptions struct {
 //
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) GetByScoreRange(minScore float64, maxScore float64, options *GetByScoreRangeOptions) []*Node {
-
+	set.evictExpired()
 	// prepare parameters
 	var limit = defaultLimit
 	if options != nil && options.Limit > 0 {
@@ -295,7 +544,8 @@ func (set *SortedSet) GetByScoreRange(minScore float64, maxScore float64, option
 			}
 		}
 
-		for x != nil && limit > 0 {
+		/* Stop at the header, whose score -inf is within a range starting with -inf. */
+		for x != nil && x != set.header && limit > 0 {
 			if excludeStart {
 				if x.score <= minScore {
 					break
@@ -360,6 +610,7 @@ func (set *SortedSet) GetByScoreRange(minScore float64, maxScore float64, option
 //
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) GetRandomByScoreRange(minScore float64, maxScore float64, options *GetByScoreRangeOptions) []*Node {
+	set.evictExpired()
 	// prepare parameters
 	var limit = defaultLimit
 	if options != nil && options.Limit > 0 {
@@ -485,6 +736,7 @@ func (set *SortedSet) findNodeByRank(start int, remove bool) (traversed int, x *
 //
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) GetByRankRange(start int, end int, remove bool) []*Node {
+	set.evictExpired()
 	start, end, reverse := set.sanitizeIndexes(start, end)
 
 	var nodes []*Node
@@ -499,7 +751,9 @@ func (set *SortedSet) GetByRankRange(start int, end int, remove bool) []*Node {
 		nodes = append(nodes, x)
 
 		if remove {
+			set.beforeChange(x.key, x)
 			set.deleteNode(x, update)
+			set.afterRemove(x)
 		}
 
 		traversed++
@@ -534,6 +788,7 @@ func (set *SortedSet) GetByRank(rank int, remove bool) *Node {
 // If node is not found, nil is returned
 // Time complexity : O(1)
 func (set *SortedSet) GetByKey(key string) *Node {
+	set.evictExpired()
 	return set.dict[key]
 }
 
@@ -544,22 +799,29 @@ func (set *SortedSet) GetByKey(key string) *Node {
 //
 // Time complexity of this method is : O(log(N))
 func (set *SortedSet) FindRank(key string) int {
-	var rank = 0
+	set.evictExpired()
 	node := set.dict[key]
 	if node != nil {
-		x := set.header
-		for i := set.level - 1; i >= 0; i-- {
-			for x.level[i].forward != nil &&
-				(x.level[i].forward.score < node.score ||
-					(math.Abs(x.level[i].forward.score-node.score) < eps &&
-						x.level[i].forward.key <= node.key)) {
-				rank += x.level[i].span
-				x = x.level[i].forward
-			}
+		return set.rankOf(node)
+	}
+	return 0
+}
 
-			if x.key == key {
-				return rank
-			}
+/* Rank of a node linked in the skiplist, 0 if it is not. */
+func (set *SortedSet) rankOf(node *Node) int {
+	var rank = 0
+	x := set.header
+	for i := set.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.score < node.score ||
+				(sameScore(x.level[i].forward.score, node.score) &&
+					x.level[i].forward.key <= node.key)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+
+		if x == node {
+			return rank
 		}
 	}
 	return 0
@@ -572,6 +834,7 @@ func (set *SortedSet) FindRank(key string) int {
 // If start is greater than end, apply fn in reserved order
 // If fn is nil, this function return without doing anything
 func (set *SortedSet) IterFuncByRankRange(start int, end int, fn func(key string, value interface{}) bool) {
+	set.evictExpired()
 	if fn == nil {
 		return
 	}
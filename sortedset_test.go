@@ -305,6 +305,147 @@ func TestBackwardAndForward(t *testing.T) {
 
 }
 
+func TestIncrBy(t *testing.T) {
+	sortedset := New()
+	sortedset.AddOrUpdate("a", 10, "Alice")
+
+	if score := sortedset.IncrBy("a", 5); score != 15 {
+		t.Errorf("IncrBy() returns %v, expected 15", score)
+	}
+	if node := sortedset.GetByKey("a"); node.Score() != 15 || node.Value != "Alice" {
+		t.Error("IncrBy() should update the score and keep the value")
+	}
+	if score := sortedset.IncrBy("b", -3); score != -3 {
+		t.Errorf("IncrBy() on a missing key returns %v, expected -3", score)
+	}
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"b", "a"})
+}
+
+func TestVersion(t *testing.T) {
+	sortedset := New()
+
+	if !sortedset.CompareAndSet("a", 0, 1, "v1") {
+		t.Error("CompareAndSet() with version 0 should add a missing key")
+	}
+	if sortedset.CompareAndSet("a", 0, 1, "v1") {
+		t.Error("CompareAndSet() with version 0 should fail on an existing key")
+	}
+	if v := sortedset.GetByKey("a").Version(); v != 1 {
+		t.Errorf("version of a new node is %d, expected 1", v)
+	}
+
+	sortedset.AddOrUpdate("a", 1, "v2") // value only
+	sortedset.IncrBy("a", 1)            // score changes, the node is replaced
+	node := sortedset.GetByKey("a")
+	if node.Version() != 3 {
+		t.Errorf("version of \"a\" is %d, expected 3", node.Version())
+	}
+	if sortedset.CompareAndSet("a", 2, 10, "stale") {
+		t.Error("CompareAndSet() with a stale version should fail")
+	}
+	if !sortedset.CompareAndSet("a", 3, 10, "v4") || sortedset.GetByKey("a").Score() != 10 {
+		t.Error("CompareAndSet() with the current version should update the node")
+	}
+
+	removed := sortedset.Remove("a")
+	if removed.Version() != 5 {
+		t.Errorf("version of a removed node is %d, expected 5", removed.Version())
+	}
+
+	// CompareAndSet x2, AddOrUpdate, IncrBy, Remove
+	if v := sortedset.Version(); v != 5 {
+		t.Errorf("version of the set is %d, expected 5", v)
+	}
+}
+
+func TestVersionABA(t *testing.T) {
+	sortedset := New()
+	sortedset.AddOrUpdate("a", 1, "first")
+	sortedset.AddOrUpdate("b", 1, nil)
+	stale := sortedset.GetByKey("a").Version()
+
+	sortedset.Remove("a")
+	sortedset.AddOrUpdate("a", 2, "second")
+	if v := sortedset.GetByKey("a").Version(); v <= stale {
+		t.Errorf("version of \"a\" added again is %d, expected more than %d", v, stale)
+	}
+	if sortedset.CompareAndSet("a", stale, 3, "stale") {
+		t.Error("CompareAndSet() with a version of a removed node should fail")
+	}
+
+	// versions keep going up after a rollback and in a clone
+	tx := sortedset.Begin()
+	sortedset.AddOrUpdate("c", 1, nil)
+	inTx := sortedset.GetByKey("c").Version()
+	tx.Rollback()
+	sortedset.AddOrUpdate("c", 1, nil)
+	if sortedset.CompareAndSet("c", inTx, 2, nil) {
+		t.Error("CompareAndSet() with a version of a rolled back node should fail")
+	}
+	clone := sortedset.Clone(nil)
+	v := clone.GetByKey("a").Version()
+	clone.Remove("a")
+	clone.AddOrUpdate("a", 2, nil)
+	if clone.CompareAndSet("a", v, 3, nil) {
+		t.Error("CompareAndSet() in a clone with a version of a removed node should fail")
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	var evicted []string
+	sortedset := New(WithMaxSize(3, EvictMin), WithEvictCallback(func(node *Node) {
+		evicted = append(evicted, node.Key())
+	}))
+
+	sortedset.AddOrUpdate("a", 10, nil)
+	sortedset.AddOrUpdate("b", 20, nil)
+	sortedset.AddOrUpdate("c", 30, nil)
+	if sortedset.AddOrUpdate("d", 5, nil) {
+		t.Error("AddOrUpdate() should reject an element which would be evicted right away")
+	}
+	if !sortedset.AddOrUpdate("e", 25, nil) {
+		t.Error("AddOrUpdate() should add an element within the top 3")
+	}
+	sortedset.IncrBy("b", 100)
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"e", "c", "b"})
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("evicted elements are %v, expected [a]", evicted)
+	}
+
+	sortedset = New(WithMaxSize(2, EvictMax))
+	sortedset.AddOrUpdate("a", 10, nil)
+	sortedset.AddOrUpdate("b", 20, nil)
+	sortedset.AddOrUpdate("c", 5, nil)
+	if sortedset.CompareAndSet("d", 0, 30, nil) {
+		t.Error("CompareAndSet() should fail when the element is rejected")
+	}
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"c", "a"})
+}
+
+func TestInfiniteScores(t *testing.T) {
+	sortedset := New()
+	sortedset.AddOrUpdate("a", math.Inf(-1), nil)
+	sortedset.AddOrUpdate("b", 0, nil)
+	sortedset.AddOrUpdate("c", math.Inf(1), nil)
+	sortedset.AddOrUpdate("d", math.Inf(-1), nil)
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"a", "d", "b", "c"})
+
+	if rank := sortedset.FindRank("d"); rank != 2 {
+		t.Errorf("FindRank(\"d\") = %d, expected 2", rank)
+	}
+	if sortedset.Remove("a") == nil || sortedset.PopMax().Key() != "c" {
+		t.Error("elements with an infinite score should be removed")
+	}
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"d", "b"})
+	checkOrder(t, sortedset.GetByScoreRange(math.Inf(-1), math.Inf(-1), nil), []string{"d"})
+
+	// reverse ranges down to -inf stop at the header
+	checkOrder(t, sortedset.GetByScoreRange(0, math.Inf(-1), nil), []string{"b", "d"})
+	sortedset.Remove("d")
+	checkOrder(t, sortedset.GetByScoreRange(-1, math.Inf(-1), nil), []string{})
+	checkOrder(t, sortedset.GetByScoreRange(0, math.Inf(-1), &GetByScoreRangeOptions{ExcludeStart: true}), []string{})
+}
+
 func BenchmarkDefaultDecrementInserts(b *testing.B) {
 	list := New()
 
@@ -10,6 +10,8 @@ type Node struct {
 	key      string      // unique key of this node
 	Value    interface{} // associated data
 	score    float64     // score to determine the order of this node in the set
+	version  uint64      // version of the set at the last change of the key
+	expireAt int64       // Unix time in nanoseconds when the node expires, 0 if it never does
 	backward *Node
 	level    []Level
 }
@@ -24,6 +26,14 @@ func (node *Node) Score() float64 {
 	return node.score
 }
 
+// Version func return the version of the node, which is the version of the
+// set (see SortedSet.Version) at the last change of its key: AddOrUpdate,
+// IncrBy, CompareAndSet, Expire and removal. It only goes up, even when the
+// key is removed and added again, so a version is never reused for a key.
+func (node *Node) Version() uint64 {
+	return node.version
+}
+
 func (node *Node) Next() *Node {
 	return node.level[0].forward
 }
@@ -0,0 +1,198 @@
+package sortedset
+
+import (
+	"container/heap"
+	"time"
+)
+
+// AddOrUpdateWithTTL Add or update an element like AddOrUpdate, and make it
+// expire after ttl. Expired elements never show up in ranks or ranges.
+// A ttl <= 0 removes the element right away, in which case false is returned.
+//
+// Time complexity of this method is : O(log(N))
+func (set *SortedSet) AddOrUpdateWithTTL(key string, score float64, value interface{}, ttl time.Duration) bool {
+	set.evictExpired()
+	if ttl <= 0 {
+		if found := set.dict[key]; found != nil {
+			set.removeNode(found)
+		}
+		return false
+	}
+	// the deadline is given to the node before listeners are notified
+	set.expiring = set.now().Add(ttl).UnixNano()
+	node, added := set.put(key, score, value)
+	set.expiring = 0
+	if node != nil {
+		set.syncExpiry(key)
+	}
+	return added
+}
+
+// Expire Set the element specified by key to expire after ttl, replacing any
+// previous TTL. A ttl <= 0 removes the element right away.
+// It returns false if the element does not exist
+//
+// Time complexity of this method is : O(log(N))
+func (set *SortedSet) Expire(key string, ttl time.Duration) bool {
+	set.evictExpired()
+	found := set.dict[key]
+	if found == nil {
+		return false
+	}
+	if ttl <= 0 {
+		set.removeNode(found)
+		return true
+	}
+	set.beforeChange(key, found)
+	delete(set.pending, key) // a TTL change is not an event
+	found.version = set.version
+	set.setExpireAt(found, set.now().Add(ttl).UnixNano())
+	set.notifyExpiry(found)
+	return true
+}
+
+// Persist Remove the TTL of the element specified by key so it never expires.
+// It returns false if the element does not exist or has no TTL
+//
+// Time complexity of this method is : O(log(N))
+func (set *SortedSet) Persist(key string) bool {
+	set.evictExpired()
+	found := set.dict[key]
+	if found == nil || found.expireAt == 0 {
+		return false
+	}
+	set.beforeChange(key, found)
+	delete(set.pending, key) // a TTL change is not an event
+	found.version = set.version
+	set.setExpireAt(found, 0)
+	set.notifyExpiry(found)
+	return true
+}
+
+// TTL Get the remaining time to live of the element specified by key.
+// The second return value is false if the element does not exist or has no TTL
+func (set *SortedSet) TTL(key string) (time.Duration, bool) {
+	set.evictExpired()
+	found := set.dict[key]
+	if found == nil || found.expireAt == 0 {
+		return 0, false
+	}
+	return time.Duration(found.expireAt - set.now().UnixNano()), true
+}
+
+// EvictExpired Remove every element whose deadline is not after now, and
+// return the number of removed elements.
+//
+// Expired elements are also removed lazily whenever the set is accessed, so
+// calling this method is only needed to release memory of sets which are not
+// accessed for a long time.
+//
+// Time complexity of this method is : O(M*log(N)), M being the number of expired elements
+func (set *SortedSet) EvictExpired(now time.Time) int {
+	if set.expires == nil {
+		return 0
+	}
+	deadline := now.UnixNano()
+	evicted := 0
+	for {
+		if set.expires.Len() == 0 || set.expires.items[0].expireAt > deadline {
+			return evicted
+		}
+		min := set.expires.items[0]
+		set.evicting = true
+		set.removeNode(set.dict[min.key])
+		set.evicting = false
+		evicted++
+	}
+}
+
+/* Remove expired elements before the set is accessed. */
+func (set *SortedSet) evictExpired() {
+	if set.expires != nil && set.expires.Len() > 0 {
+		set.EvictExpired(set.now())
+	}
+}
+
+/* Change the deadline of node, 0 meaning it never expires, and keep the
+ * expiry index in sync. */
+func (set *SortedSet) setExpireAt(node *Node, expireAt int64) {
+	node.expireAt = expireAt
+	set.syncExpiry(node.key)
+}
+
+/* Make the expiry index reflect the node currently holding key. */
+func (set *SortedSet) syncExpiry(key string) {
+	node := set.dict[key]
+	if node == nil || node.expireAt == 0 {
+		if set.expires != nil {
+			set.expires.remove(key)
+		}
+		return
+	}
+	if set.expires == nil {
+		set.expires = &expiryQueue{index: make(map[string]int)}
+	}
+	set.expires.set(key, node.expireAt)
+}
+
+// expiryQueue is a min-heap of the deadlines of the nodes having a TTL,
+// ordered by their exact Unix time in nanoseconds
+type expiryQueue struct {
+	items []expiry
+	index map[string]int // position of every key in items
+}
+
+type expiry struct {
+	key      string
+	expireAt int64
+}
+
+func (q *expiryQueue) Len() int { return len(q.items) }
+
+func (q *expiryQueue) Less(i, j int) bool { return q.items[i].expireAt < q.items[j].expireAt }
+
+func (q *expiryQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.index[q.items[i].key] = i
+	q.index[q.items[j].key] = j
+}
+
+func (q *expiryQueue) Push(x interface{}) {
+	item := x.(expiry)
+	q.index[item.key] = len(q.items)
+	q.items = append(q.items, item)
+}
+
+func (q *expiryQueue) Pop() interface{} {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	delete(q.index, item.key)
+	return item
+}
+
+/* Add key with its deadline, or move it to its new deadline. */
+func (q *expiryQueue) set(key string, expireAt int64) {
+	if i, ok := q.index[key]; ok {
+		q.items[i].expireAt = expireAt
+		heap.Fix(q, i)
+		return
+	}
+	heap.Push(q, expiry{key: key, expireAt: expireAt})
+}
+
+func (q *expiryQueue) remove(key string) {
+	if i, ok := q.index[key]; ok {
+		heap.Remove(q, i)
+	}
+}
+
+func (q *expiryQueue) clone() *expiryQueue {
+	c := &expiryQueue{
+		items: append([]expiry(nil), q.items...),
+		index: make(map[string]int, len(q.index)),
+	}
+	for key, i := range q.index {
+		c.index[key] = i
+	}
+	return c
+}
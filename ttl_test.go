@@ -0,0 +1,102 @@
+package sortedset
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sortedset := New(WithClock(func() time.Time { return now }))
+
+	sortedset.AddOrUpdateWithTTL("a", 1, "Alice", time.Minute)
+	sortedset.AddOrUpdate("b", 2, "Bob")
+	sortedset.AddOrUpdate("c", 3, "Carol")
+	sortedset.Expire("c", time.Hour)
+
+	if ttl, ok := sortedset.TTL("a"); !ok || ttl != time.Minute {
+		t.Errorf("TTL() returns %v, %v, expected 1m", ttl, ok)
+	}
+	if _, ok := sortedset.TTL("b"); ok {
+		t.Error("TTL() of an element without TTL should return false")
+	}
+
+	// changing the score keeps the TTL
+	sortedset.AddOrUpdate("a", 10, "Alice")
+
+	now = now.Add(time.Minute)
+	if sortedset.GetByKey("a") != nil || sortedset.FindRank("a") != 0 {
+		t.Error("\"a\" should have expired")
+	}
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"b", "c"})
+
+	if !sortedset.Persist("c") || sortedset.Persist("c") {
+		t.Error("Persist() should remove the TTL only once")
+	}
+	now = now.Add(2 * time.Hour)
+	if sortedset.GetCount() != 2 {
+		t.Error("persisted element should not expire")
+	}
+
+	if sortedset.Expire("missing", time.Minute) {
+		t.Error("Expire() on a missing element should return false")
+	}
+	sortedset.Expire("b", 0)
+	checkOrder(t, sortedset.GetByScoreRange(0, 10, nil), []string{"c"})
+}
+
+func TestEvictExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sortedset := New(WithClock(func() time.Time { return now }))
+
+	for i, key := range []string{"a", "b", "c", "d"} {
+		sortedset.AddOrUpdateWithTTL(key, float64(i), nil, time.Duration(i+1)*time.Second)
+	}
+
+	if evicted := sortedset.EvictExpired(now.Add(2 * time.Second)); evicted != 2 {
+		t.Errorf("EvictExpired() returns %d, expected 2", evicted)
+	}
+	if sortedset.length != 2 || sortedset.expires.Len() != 2 {
+		t.Error("evicted elements should be removed from the set and the expiry index")
+	}
+
+	sortedset.Remove("c")
+	if sortedset.expires.Len() != 1 {
+		t.Error("removed element should be removed from the expiry index")
+	}
+}
+
+func TestTTLRollback(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sortedset := New(WithClock(func() time.Time { return now }))
+	sortedset.AddOrUpdateWithTTL("a", 1, nil, time.Minute)
+
+	tx := sortedset.Begin()
+	sortedset.Persist("a")
+	sortedset.AddOrUpdateWithTTL("b", 2, nil, time.Second)
+	tx.Rollback()
+
+	if ttl, ok := sortedset.TTL("a"); !ok || ttl != time.Minute {
+		t.Errorf("Rollback() should restore the TTL, got %v, %v", ttl, ok)
+	}
+	if sortedset.expires.Len() != 1 {
+		t.Error("Rollback() should restore the expiry index")
+	}
+}
+
+func TestExpiryOrder(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sortedset := New(WithClock(func() time.Time { return now }))
+
+	// deadlines 1ns apart, which float64 Unix nanoseconds cannot tell apart
+	sortedset.AddOrUpdateWithTTL("a", 1, nil, 2*time.Nanosecond)
+	sortedset.AddOrUpdateWithTTL("b", 2, nil, time.Nanosecond)
+	now = now.Add(time.Nanosecond)
+	if sortedset.GetByKey("b") != nil || sortedset.GetByKey("a") == nil {
+		t.Error("only \"b\" should have expired")
+	}
+	now = now.Add(time.Nanosecond)
+	if sortedset.GetCount() != 0 {
+		t.Error("\"a\" should have expired")
+	}
+}
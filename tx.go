@@ -0,0 +1,135 @@
+package sortedset
+
+import "errors"
+
+// ErrTxClosed is returned when committing or rolling back a transaction
+// which has already been committed or rolled back.
+var ErrTxClosed = errors.New("sortedset: transaction has already been committed or rolled back")
+
+// Tx is a transaction over a SortedSet, created by Begin.
+//
+// Changes made during the transaction are applied to the set immediately and
+// journaled, so reads inside the transaction see its own writes. Every
+// mutation of the set counts as part of the transaction until it is
+// committed or rolled back, whether it is made through the Tx or through the
+// SortedSet itself.
+type Tx struct {
+	set     *SortedSet
+	journal []txEntry
+}
+
+// txEntry records the state of a key right before it was changed.
+type txEntry struct {
+	key      string
+	node     *Node       // node holding the key before the change, nil if the key was absent
+	value    interface{} // value of node before the change
+	version  uint64      // version of node before the change
+	expireAt int64       // deadline of node before the change
+}
+
+// Begin start a new transaction on the set.
+//
+// Only one transaction can be in progress at a time, Begin panics if the set
+// already has one.
+func (set *SortedSet) Begin() *Tx {
+	if set.tx != nil {
+		panic("sortedset: a transaction is already in progress")
+	}
+	set.tx = &Tx{set: set}
+	return set.tx
+}
+
+/* Journal the state of key before it is changed, if a transaction is in progress. */
+func (set *SortedSet) record(key string, node *Node) {
+	if set.tx == nil {
+		return
+	}
+	entry := txEntry{key: key, node: node}
+	if node != nil {
+		entry.value = node.Value
+		entry.version = node.version
+		entry.expireAt = node.expireAt
+	}
+	set.tx.journal = append(set.tx.journal, entry)
+}
+
+// AddOrUpdate Add or update an element within the transaction, see SortedSet.AddOrUpdate
+func (tx *Tx) AddOrUpdate(key string, score float64, value interface{}) bool {
+	return tx.set.AddOrUpdate(key, score, value)
+}
+
+// Remove Delete an element within the transaction, see SortedSet.Remove
+func (tx *Tx) Remove(key string) *Node {
+	return tx.set.Remove(key)
+}
+
+// PopMin get and remove the element with minimal score within the transaction, see SortedSet.PopMin
+func (tx *Tx) PopMin() *Node {
+	return tx.set.PopMin()
+}
+
+// PopMax get and remove the element with maximum score within the transaction, see SortedSet.PopMax
+func (tx *Tx) PopMax() *Node {
+	return tx.set.PopMax()
+}
+
+// GetByKey Get node by key, including the changes made by the transaction
+func (tx *Tx) GetByKey(key string) *Node {
+	return tx.set.GetByKey(key)
+}
+
+// FindRank Find the rank of the node specified by key, including the changes made by the transaction
+func (tx *Tx) FindRank(key string) int {
+	return tx.set.FindRank(key)
+}
+
+// Commit keep every change made during the transaction and end it
+func (tx *Tx) Commit() error {
+	if tx.set == nil || tx.set.tx != tx {
+		return ErrTxClosed
+	}
+	tx.set.tx = nil
+	tx.set = nil
+	tx.journal = nil
+	return nil
+}
+
+// Rollback undo every change made during the transaction and end it.
+//
+// Nodes that were removed or replaced during the transaction are linked back
+// with their original levels, so the skiplist is restored to exactly its
+// previous state and the previously returned *Node pointers stay valid.
+func (tx *Tx) Rollback() error {
+	if tx.set == nil || tx.set.tx != tx {
+		return ErrTxClosed
+	}
+	set := tx.set
+	set.tx = nil // stop journaling while undoing
+	if len(tx.journal) > 0 {
+		set.version++
+	}
+
+	for i := len(tx.journal) - 1; i >= 0; i-- {
+		entry := tx.journal[i]
+		current := set.dict[entry.key]
+		set.remember(entry.key, current)
+		if current != nil && current != entry.node {
+			set.delete(current.score, current.key)
+		}
+		if entry.node != nil {
+			if current != entry.node {
+				set.linkNode(entry.node, nil)
+				set.dict[entry.key] = entry.node
+			}
+			entry.node.Value = entry.value
+			entry.node.version = entry.version
+			entry.node.expireAt = entry.expireAt
+		}
+		set.syncExpiry(entry.key)
+		set.afterChange(entry.key, set.dict[entry.key])
+	}
+
+	tx.set = nil
+	tx.journal = nil
+	return nil
+}
@@ -0,0 +1,103 @@
+package sortedset
+
+import (
+	"fmt"
+	"testing"
+)
+
+type levelSnapshot struct {
+	forward string
+	span    int
+}
+
+// snapshotSkiplist captures the full skiplist layout so two states can be compared
+func snapshotSkiplist(set *SortedSet) map[*Node][]levelSnapshot {
+	snapshot := make(map[*Node][]levelSnapshot)
+	for x := set.header; x != nil; x = x.level[0].forward {
+		levels := x.level
+		if x == set.header {
+			levels = levels[:set.level]
+		}
+		for _, l := range levels {
+			forward := "<nil>"
+			if l.forward != nil {
+				forward = l.forward.key
+			}
+			snapshot[x] = append(snapshot[x], levelSnapshot{forward: forward, span: l.span})
+		}
+	}
+	return snapshot
+}
+
+func checkSnapshot(t *testing.T, set *SortedSet, expected map[*Node][]levelSnapshot) {
+	actual := snapshotSkiplist(set)
+	if len(actual) != len(expected) {
+		t.Fatalf("skiplist has %d nodes, expected %d", len(actual), len(expected))
+	}
+	for node, levels := range expected {
+		if fmt.Sprint(actual[node]) != fmt.Sprint(levels) {
+			t.Errorf("levels of %q are %v, expected %v", node.key, actual[node], levels)
+		}
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	sortedset := New()
+	for i := 0; i < 100; i++ {
+		sortedset.AddOrUpdate(fmt.Sprintf("%02d", i), float64(i%10), i)
+	}
+	before := snapshotSkiplist(sortedset)
+	node5 := sortedset.GetByKey("05")
+	version5 := node5.Version()
+	node7 := sortedset.GetByKey("07")
+
+	tx := sortedset.Begin()
+	tx.AddOrUpdate("new", 3, "new")
+	tx.AddOrUpdate("05", 100, "moved")
+	tx.AddOrUpdate("07", 7, "value only")
+	if tx.GetByKey("new") == nil || tx.GetByKey("05").Score() != 100 {
+		t.Error("reads inside the transaction should see its own writes")
+	}
+	tx.Remove("10")
+	tx.PopMin()
+	tx.PopMax()
+	sortedset.GetByRankRange(10, 20, true)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkSnapshot(t, sortedset, before)
+	if sortedset.GetCount() != 100 || sortedset.GetByKey("new") != nil {
+		t.Error("Rollback() should restore the number of elements")
+	}
+	if sortedset.GetByKey("05") != node5 || node5.Value != 5 || node5.Version() != version5 {
+		t.Error("Rollback() should restore the original node of \"05\"")
+	}
+	if sortedset.GetByKey("07") != node7 || node7.Value != 7 {
+		t.Error("Rollback() should restore the value of \"07\"")
+	}
+	if err := tx.Commit(); err != ErrTxClosed {
+		t.Errorf("Commit() after Rollback() should return ErrTxClosed, got %v", err)
+	}
+}
+
+func TestTxCommit(t *testing.T) {
+	sortedset := New()
+	sortedset.AddOrUpdate("a", 1, nil)
+	sortedset.AddOrUpdate("b", 2, nil)
+
+	tx := sortedset.Begin()
+	tx.AddOrUpdate("c", 0, nil)
+	tx.Remove("b")
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, sortedset.GetByRankRange(1, -1, false), []string{"c", "a"})
+
+	if err := tx.Rollback(); err != ErrTxClosed {
+		t.Errorf("Rollback() after Commit() should return ErrTxClosed, got %v", err)
+	}
+	// a new transaction can start once the previous one is done
+	sortedset.Begin().Rollback()
+}
@@ -0,0 +1,85 @@
+package sortedset
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCorrupt is wrapped by the error Verify returns for an inconsistent set
+var ErrCorrupt = errors.New("sortedset: corrupt set")
+
+// Verify Check the structure of the set: the order of the elements, the
+// links and spans of every level of the skiplist, the index by key, the
+// number of elements and the digest. The first inconsistency found is
+// returned as an error wrapping ErrCorrupt, nil if there is none.
+//
+// A set changed only through its methods is always consistent, Verify is
+// meant for tests and tools checking a set loaded from a file.
+//
+// Time complexity of this method is : O(N)
+func (set *SortedSet) Verify() error {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))
+	}
+
+	ranks := make(map[*Node]int, set.length)
+	var digest uint64
+	var prev *Node
+	for x := set.header.level[0].forward; x != nil; prev, x = x, x.level[0].forward {
+		rank := len(ranks) + 1
+		if rank > set.length {
+			return fail("more nodes are linked than the %d the set counts", set.length)
+		}
+		if prev != nil && !ordered(prev.score, prev.key, x.score, x.key) {
+			return fail("rank %d: %q (%v) is out of order after %q (%v)", rank, x.key, x.score, prev.key, prev.score)
+		}
+		if x.backward != prev {
+			return fail("rank %d: %q has a wrong backward link", rank, x.key)
+		}
+		if set.dict[x.key] != x {
+			return fail("rank %d: %q is not indexed by its key", rank, x.key)
+		}
+		if len(x.level) > set.level {
+			return fail("rank %d: %q has %d levels, the set %d", rank, x.key, len(x.level), set.level)
+		}
+		ranks[x] = rank
+		digest += elementHash(x.key, x.score)
+	}
+	if len(ranks) != set.length {
+		return fail("%d nodes are linked, the set counts %d", len(ranks), set.length)
+	}
+	if len(set.dict) != set.length {
+		return fail("%d keys are indexed, the set counts %d", len(set.dict), set.length)
+	}
+	if set.tail != prev {
+		return fail("the tail is not the last node")
+	}
+	if digest != set.digest {
+		return fail("the digest does not match the elements")
+	}
+
+	for i := 0; i < SkiplistMaxLevel; i++ {
+		if i >= set.level {
+			if set.header.level[i].forward != nil {
+				return fail("level %d is linked above the level %d of the set", i+1, set.level)
+			}
+			continue
+		}
+		/* every forward link skips span ranks */
+		rank := 0
+		for x := set.header; x.level[i].forward != nil; x = x.level[i].forward {
+			next := x.level[i].forward
+			if ranks[next] <= rank {
+				return fail("level %d: the link after rank %d goes back to rank %d", i+1, rank, ranks[next])
+			}
+			if ranks[next] != rank+x.level[i].span {
+				return fail("level %d: the span after rank %d is %d, %q has the rank %d", i+1, rank, x.level[i].span, next.key, ranks[next])
+			}
+			if len(next.level) <= i {
+				return fail("level %d: %q is linked but has %d levels", i+1, next.key, len(next.level))
+			}
+			rank = ranks[next]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package sortedset
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	newSet := func() *SortedSet {
+		set := New()
+		for i := 0; i < 200; i++ {
+			set.AddOrUpdate(fmt.Sprint("key", i), float64(i%20), nil)
+		}
+		set.AddOrUpdate("min", math.Inf(-1), nil)
+		set.AddOrUpdate("max", math.Inf(1), nil)
+		return set
+	}
+	if err := newSet().Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		corrupt func(set *SortedSet)
+	}{
+		{"swapped scores", func(set *SortedSet) {
+			a, b := set.GetByRank(5, false), set.GetByRank(150, false)
+			a.score, b.score = b.score, a.score
+		}},
+		{"backward link", func(set *SortedSet) {
+			set.GetByRank(10, false).backward = nil
+		}},
+		{"span", func(set *SortedSet) {
+			set.header.level[set.level-1].span++
+		}},
+		{"length", func(set *SortedSet) {
+			set.length--
+		}},
+		{"dict", func(set *SortedSet) {
+			delete(set.dict, "key7")
+		}},
+		{"tail", func(set *SortedSet) {
+			set.tail = set.tail.backward
+		}},
+		{"digest", func(set *SortedSet) {
+			set.digest++
+		}},
+	} {
+		set := newSet()
+		test.corrupt(set)
+		if err := set.Verify(); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: Verify() = %v, expected ErrCorrupt", test.name, err)
+		}
+	}
+}
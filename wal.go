@@ -0,0 +1,359 @@
+package sortedset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy determines how often the write-ahead log is flushed to stable storage
+type SyncPolicy int
+
+const (
+	SyncAlways      SyncPolicy = iota // fsync after every record, the safest and slowest
+	SyncEverySecond                   // fsync once per second, losing at most one second of changes
+	SyncNever                         // never fsync, leaving it to the operating system
+)
+
+const (
+	walRecordHeaderSize = 8       // length and CRC32 of the payload, both uint32
+	walMaxRecordSize    = 1 << 28 // larger payloads are refused, and read as corruption
+	walOpSet            = 'S'
+	walOpRemove         = 'D'
+)
+
+// ErrCorruptLog is returned by OpenWAL when a record in the middle of the log is damaged
+var ErrCorruptLog = errors.New("sortedset: corrupt write-ahead log")
+
+// WAL is an append-only log of the changes made to a SortedSet, created by
+// OpenWAL. It records the resulting state of every changed key, so the set
+// can be rebuilt after a crash by replaying the log.
+//
+// Every change is logged, including the ones made by PopMin/PopMax,
+// GetByRankRange with remove, evictions and rolled back transactions. The
+// deadline of an element with a TTL is logged with it, and logged again when
+// it is changed by Expire or Persist.
+type WAL struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	policy   SyncPolicy
+	codec    ValueCodec
+	set      *SortedSet
+	listener *listener
+	dirty    bool  // records were written since the last fsync
+	err      error // first error while writing, reported by Sync, Rewrite and Close
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+	closeErr error // result of the first Close
+}
+
+// OpenWAL Create a new SortedSet with the given options, replay the log at
+// path into it and log every later change of the set to the same file. The
+// file is created if it does not exist.
+//
+// A record which was only partially written at the end of the log, because
+// of a crash, is discarded. ErrCorruptLog is returned if any other record is
+// damaged.
+func OpenWAL(path string, policy SyncPolicy, options ...Option) (*SortedSet, *WAL, error) {
+	set := New(options...)
+	w := &WAL{
+		path:   path,
+		policy: policy,
+		codec:  set.valueCodec(),
+		set:    set,
+		done:   make(chan struct{}),
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	valid, err := w.replay(file)
+	if err == nil {
+		// drop a partially written record, then append after the valid ones
+		if err = file.Truncate(valid); err == nil {
+			_, err = file.Seek(valid, io.SeekStart)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	w.file = file
+	w.listener = set.listen(w.record)
+	w.listener.expiry = true
+
+	if policy == SyncEverySecond {
+		w.wg.Add(1)
+		go w.syncEverySecond()
+	}
+	return set, w, nil
+}
+
+/* Apply every record of the log to the set and return the size of the valid
+ * part of the log. */
+func (w *WAL) replay(file *os.File) (int64, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return 0, err
+	}
+
+	offset := 0
+	for offset < len(data) {
+		rest := data[offset:]
+		if len(rest) < walRecordHeaderSize {
+			break // torn header
+		}
+		size := int(binary.BigEndian.Uint32(rest))
+		if size > walMaxRecordSize {
+			return 0, fmt.Errorf("%w: record of %d bytes at offset %d", ErrCorruptLog, size, offset)
+		}
+		if len(rest)-walRecordHeaderSize < size {
+			// a torn record is the last one, no whole record can follow it
+			if containsWALRecord(rest[1:]) {
+				return 0, fmt.Errorf("%w: record of %d bytes at offset %d runs past the end of the log", ErrCorruptLog, size, offset)
+			}
+			break // torn payload
+		}
+		payload := rest[walRecordHeaderSize : walRecordHeaderSize+size]
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(rest[4:]) {
+			if offset+walRecordHeaderSize+size == len(data) {
+				break // the last record was torn
+			}
+			return 0, fmt.Errorf("%w: bad checksum at offset %d", ErrCorruptLog, offset)
+		}
+		if err := applyWALPayload(w.set, payload, w.codec, true); err != nil {
+			return 0, fmt.Errorf("%w: %v at offset %d", ErrCorruptLog, err, offset)
+		}
+		offset += walRecordHeaderSize + size
+	}
+	return int64(offset), nil
+}
+
+/* Whether a whole record with a valid checksum starts anywhere in data, so
+ * the bytes before it cannot be a record torn by a crash. */
+func containsWALRecord(data []byte) bool {
+	for i := 0; i+walRecordHeaderSize <= len(data); i++ {
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + walRecordHeaderSize + size
+		if size <= walMaxRecordSize && end <= len(data) &&
+			crc32.ChecksumIEEE(data[i+walRecordHeaderSize:end]) == binary.BigEndian.Uint32(data[i+4:]) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Apply the payload of a record to set, it is shared by the log and the
+ * replication stream. The deadline of the element is ignored unless ttl. */
+func applyWALPayload(set *SortedSet, payload []byte, codec ValueCodec, ttl bool) error {
+	if len(payload) == 0 {
+		return ErrInvalidData
+	}
+	r := binaryReader{data: payload[1:]}
+	switch payload[0] {
+	case walOpSet:
+		key, score, value, err := r.node(codec)
+		if err != nil {
+			return err
+		}
+		expireAt := int64(r.uvarint())
+		if r.err != nil {
+			return r.err
+		}
+		set.AddOrUpdate(key, score, value)
+		if !ttl {
+			break
+		}
+		if node := set.dict[key]; node != nil && node.expireAt != expireAt {
+			set.setExpireAt(node, expireAt) // expired elements are evicted lazily
+		}
+	case walOpRemove:
+		key := string(r.bytes(r.uvarint()))
+		if r.err != nil {
+			return r.err
+		}
+		set.Remove(key)
+	default:
+		return fmt.Errorf("unknown operation %q", payload[0])
+	}
+	if len(r.data) != 0 {
+		return ErrInvalidData
+	}
+	return nil
+}
+
+/* Append a record for key, node being the node now holding it or nil if it was removed. */
+func (w *WAL) record(e Event) {
+	buf, err := appendWALRecord(make([]byte, 0, 64), e.Key, e.Node, w.codec)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	if err == nil {
+		_, err = w.file.Write(buf)
+	}
+	if err == nil {
+		if w.policy == SyncAlways {
+			err = w.file.Sync()
+		} else {
+			w.dirty = true
+		}
+	}
+	w.err = err
+}
+
+func appendWALRecord(buf []byte, key string, node *Node, codec ValueCodec) ([]byte, error) {
+	start := len(buf)
+	buf = append(buf, make([]byte, walRecordHeaderSize)...)
+	buf, err := appendWALPayload(buf, key, node, codec)
+	if err != nil {
+		return nil, err
+	}
+	if size := len(buf) - start - walRecordHeaderSize; size > walMaxRecordSize {
+		return nil, fmt.Errorf("sortedset: record of %d bytes for %q is too large to be logged", size, key)
+	}
+	return sealWALRecord(buf, start), nil
+}
+
+/* Append the operation setting key to the state of node, its deadline
+ * included, or removing it if node is nil. */
+func appendWALPayload(buf []byte, key string, node *Node, codec ValueCodec) ([]byte, error) {
+	if node == nil {
+		buf = append(buf, walOpRemove)
+		buf = binary.AppendUvarint(buf, uint64(len(key)))
+		return append(buf, key...), nil
+	}
+	buf = append(buf, walOpSet)
+	buf, err := appendBinaryNode(buf, key, node.score, node.Value, codec)
+	if err != nil {
+		return nil, err
+	}
+	return binary.AppendUvarint(buf, uint64(node.expireAt)), nil
+}
+
+/* Fill the header of the record starting at start, its payload being the
+ * rest of buf. */
+func sealWALRecord(buf []byte, start int) []byte {
+	payload := buf[start+walRecordHeaderSize:]
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[start+4:], crc32.ChecksumIEEE(payload))
+	return buf
+}
+
+func (w *WAL) syncEverySecond() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.Sync()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Sync flush the log to stable storage. It returns the first error which
+// happened while writing the log, if any
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sync()
+}
+
+func (w *WAL) sync() error {
+	if w.err == nil && w.dirty {
+		w.err = w.file.Sync()
+		w.dirty = false
+	}
+	return w.err
+}
+
+// Rewrite compact the log, like AOF rewrite in Redis: the log is replaced by
+// the shortest one leading to the current state of the set, with one record
+// per element. The new log is written to a temporary file which atomically
+// replaces the old one.
+//
+// Like the set itself, Rewrite must not be called concurrently with changes of the set.
+//
+// Time complexity of this method is : O(N)
+func (w *WAL) Rewrite() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+
+	tmpPath := w.path + ".rewrite"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err = w.writeState(tmp); err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, w.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(w.path))
+
+	w.file.Close()
+	w.file = tmp
+	w.dirty = false
+	return nil
+}
+
+func (w *WAL) writeState(file *os.File) error {
+	buf := make([]byte, 0, 64*1024)
+	for x := w.set.header.level[0].forward; x != nil; x = x.level[0].forward {
+		var err error
+		if buf, err = appendWALRecord(buf, x.key, x, w.codec); err != nil {
+			return err
+		}
+		if len(buf) >= 32*1024 {
+			if _, err := file.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	_, err := file.Write(buf)
+	return err
+}
+
+// Close stop logging the changes of the set, flush the log to stable storage
+// and close it. It returns the first error which happened while writing the
+// log, if any. Closing it again returns the same error.
+func (w *WAL) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		w.set.unlisten(w.listener)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		err := w.sync()
+		if closeErr := w.file.Close(); err == nil {
+			err = closeErr
+		}
+		w.closeErr = err
+	})
+	return w.closeErr
+}
@@ -0,0 +1,196 @@
+package sortedset
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func checkSameNodes(t *testing.T, actual *SortedSet, expected *SortedSet) {
+	t.Helper()
+	actualNodes := actual.GetByRankRange(1, -1, false)
+	expectedNodes := expected.GetByRankRange(1, -1, false)
+	if len(actualNodes) != len(expectedNodes) {
+		t.Fatalf("set has %d nodes, expected %d", len(actualNodes), len(expectedNodes))
+	}
+	for i := range expectedNodes {
+		if actualNodes[i].Key() != expectedNodes[i].Key() || actualNodes[i].Score() != expectedNodes[i].Score() || actualNodes[i].Value != expectedNodes[i].Value {
+			t.Errorf("node %d is %q %v %v, expected %q %v %v", i,
+				actualNodes[i].Key(), actualNodes[i].Score(), actualNodes[i].Value,
+				expectedNodes[i].Key(), expectedNodes[i].Score(), expectedNodes[i].Value)
+		}
+	}
+}
+
+func TestWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.wal")
+
+	sortedset, wal, err := OpenWAL(path, SyncAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortedset.AddOrUpdate("a", 89, "Kelly")
+	sortedset.AddOrUpdate("b", 100, "Staley")
+	sortedset.AddOrUpdate("c", 100, "Jordon")
+	sortedset.AddOrUpdate("d", -321, "Park")
+	sortedset.AddOrUpdate("e", 101, "Albert")
+	sortedset.AddOrUpdate("e", 99, "ntrnrt")
+	sortedset.IncrBy("a", 1)
+	sortedset.Remove("b")
+	sortedset.PopMin()
+	sortedset.GetByRankRange(-1, -1, true)
+	tx := sortedset.Begin()
+	sortedset.Remove("a")
+	tx.Rollback()
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameNodes(t, replayed, sortedset)
+	checkRanks(t, replayed)
+
+	// changes after a replay are appended
+	replayed.AddOrUpdate("f", 1, "Lyman")
+	before, _ := os.Stat(path)
+	if err := wal.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("Rewrite() should shrink the log from %d bytes, got %d", before.Size(), after.Size())
+	}
+	replayed.AddOrUpdate("g", 2, nil)
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	again, wal, err := OpenWAL(path, SyncEverySecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	checkSameNodes(t, again, replayed)
+}
+
+func TestWALRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.wal")
+	sortedset, wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortedset.AddOrUpdate("a", 1, "Alice")
+	sortedset.AddOrUpdate("b", 2, "Bob")
+	wal.Close()
+	data, _ := os.ReadFile(path)
+
+	// a record torn by a crash is dropped
+	os.WriteFile(path, data[:len(data)-3], 0644)
+	recovered, wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, recovered.GetByRankRange(1, -1, false), []string{"a"})
+	recovered.AddOrUpdate("c", 3, nil)
+	wal.Close()
+	recovered, wal, err = OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, recovered.GetByRankRange(1, -1, false), []string{"a", "c"})
+	wal.Close()
+
+	// damage in the middle of the log is reported
+	corrupted := append([]byte{}, data...)
+	corrupted[walRecordHeaderSize+2] ^= 0xFF
+	os.WriteFile(path, corrupted, 0644)
+	if _, _, err := OpenWAL(path, SyncNever); !errors.Is(err, ErrCorruptLog) {
+		t.Errorf("OpenWAL() of a corrupt log returns %v", err)
+	}
+}
+
+func TestWALCorruptLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.wal")
+	sortedset, wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		sortedset.AddOrUpdate(fmt.Sprint("key", i), float64(i), nil)
+	}
+	wal.Close()
+	data, _ := os.ReadFile(path)
+	third := 0
+	for i := 0; i < 2; i++ {
+		third += walRecordHeaderSize + int(binary.BigEndian.Uint32(data[third:]))
+	}
+
+	// a length running past the end of the log, or over the limit, is not taken for a torn record
+	for _, size := range []uint32{uint32(len(data)), walMaxRecordSize + 1} {
+		corrupted := append([]byte{}, data...)
+		binary.BigEndian.PutUint32(corrupted[third:], size)
+		os.WriteFile(path, corrupted, 0644)
+		if _, _, err := OpenWAL(path, SyncNever); !errors.Is(err, ErrCorruptLog) {
+			t.Errorf("OpenWAL() of a log with a record of %d bytes returns %v", size, err)
+		}
+		if info, _ := os.Stat(path); info.Size() != int64(len(data)) {
+			t.Errorf("the corrupt log was truncated to %d bytes, expected %d", info.Size(), len(data))
+		}
+	}
+}
+
+func TestWALTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.wal")
+	sortedset, wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortedset.AddOrUpdateWithTTL("a", 1, nil, time.Hour)
+	sortedset.AddOrUpdate("b", 2, nil)
+	sortedset.Expire("b", 2*time.Hour)
+	sortedset.AddOrUpdateWithTTL("c", 3, nil, time.Hour)
+	sortedset.Persist("c")
+	sortedset.AddOrUpdateWithTTL("d", 4, nil, time.Hour)
+	sortedset.AddOrUpdate("d", 5, nil) // keeps its TTL
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Errorf("a second Close() returns %v", err)
+	}
+
+	checkTTLs := func(replayed *SortedSet) {
+		t.Helper()
+		checkSameNodes(t, replayed, sortedset)
+		for _, key := range []string{"a", "b", "c", "d"} {
+			expected, hasTTL := sortedset.TTL(key)
+			ttl, ok := replayed.TTL(key)
+			if ok != hasTTL || expected-ttl > time.Minute || ttl-expected > time.Minute {
+				t.Errorf("TTL(%q) after replay = %v %v, expected %v %v", key, ttl, ok, expected, hasTTL)
+			}
+		}
+	}
+	replayed, wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTTLs(replayed)
+	if err := wal.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	replayed, wal, err = OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	checkTTLs(replayed)
+}
@@ -0,0 +1,96 @@
+package sortedset
+
+// RankChange reports a change of the rank of a watched key, see Watch
+type RankChange struct {
+	Key     string // the watched key
+	OldRank int    // rank before the change, 0 if the key was not in the set
+	Rank    int    // rank after the change, 0 if the key is not in the set
+	By      string // key whose change moved the watched key, Key itself if it changed
+}
+
+// Watch register fn to be called every time the rank of key changes, either
+// because key itself is added, updated or removed, or because another element
+// is inserted before it, removed before it or moved across it, e.g. when a
+// player is overtaken. Like Subscribe, fn is called right after the change
+// and must not change the set. The key does not need to be in the set yet.
+//
+// Ranks are not searched again on every change: the rank of key is kept and
+// only shifted according to the position of the changed element relative to
+// key, so it is searched in the skiplist only when key itself changes.
+//
+// Time complexity of this method is : O(log(N)), then O(1) for every change of another key
+func (set *SortedSet) Watch(key string, fn func(c RankChange)) *Subscription {
+	set.evictExpired()
+	w := &watcher{set: set, key: key, fn: fn}
+	if node := set.dict[key]; node != nil {
+		w.rank = set.rankOf(node)
+	}
+	return &Subscription{set: set, l: set.listen(w.changed)}
+}
+
+// watcher keeps the rank of a watched key up to date from the events of the set
+type watcher struct {
+	set  *SortedSet
+	key  string
+	rank int // 0 if key is not in the set
+	fn   func(c RankChange)
+}
+
+func (w *watcher) changed(e Event) {
+	set := w.set
+	if e.Key == w.key {
+		w.moved(e)
+		return
+	}
+	if w.rank == 0 {
+		return
+	}
+	node := set.dict[w.key]
+	if _, changing := set.pending[w.key]; changing || node == nil {
+		return // the rank is searched again when the change of key is notified
+	}
+	delta := 0
+	if e.Type != EventAdded && before(e.OldScore, e.Key, node) {
+		delta-- // the old position was before key
+	}
+	if e.Node != nil && before(e.Score, e.Key, node) {
+		delta++ // the new position is before key
+	}
+	if delta != 0 {
+		old := w.rank
+		w.rank += delta
+		w.fn(RankChange{Key: w.key, OldRank: old, Rank: w.rank, By: e.Key})
+	}
+}
+
+/* Search the rank of the watched key after its own change. */
+func (w *watcher) moved(e Event) {
+	set := w.set
+	rank := 0
+	if e.Node != nil {
+		rank = set.rankOf(e.Node)
+		// batches change several keys before notifying them: the rank must
+		// not count the changes which are not notified yet, as they will
+		// shift it when they are
+		for key, p := range set.pending {
+			if p.exists {
+				// removed by AddOrUpdateBatch, and not inserted again yet
+				if before(p.score, key, e.Node) {
+					rank++
+				}
+			} else if x := set.dict[key]; x != nil && before(x.score, key, e.Node) {
+				rank-- // linked by BulkLoad or replaceWith
+			}
+		}
+	}
+	if rank != w.rank {
+		old := w.rank
+		w.rank = rank
+		w.fn(RankChange{Key: w.key, OldRank: old, Rank: rank, By: w.key})
+	}
+}
+
+/* Whether an element with score and key is ordered before node. */
+func before(score float64, key string, node *Node) bool {
+	return ordered(score, key, node.score, node.key)
+}
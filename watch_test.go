@@ -0,0 +1,122 @@
+package sortedset
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	set := New()
+	set.AddOrUpdate("me", 50, nil)
+	set.AddOrUpdate("low", 10, nil)
+
+	var changes []RankChange
+	sub := set.Watch("me", func(c RankChange) {
+		changes = append(changes, c)
+	})
+	check := func(expected ...RankChange) {
+		t.Helper()
+		if fmt.Sprint(changes) != fmt.Sprint(expected) {
+			t.Errorf("rank changes are %v, expected %v", changes, expected)
+		}
+		changes = nil
+	}
+
+	set.AddOrUpdate("high", 100, nil) // after me
+	set.AddOrUpdate("rival", 40, nil)
+	set.AddOrUpdate("rival", 60, nil) // overtakes me
+	check(RankChange{"me", 2, 3, "rival"}, RankChange{"me", 3, 2, "rival"})
+
+	set.AddOrUpdate("me", 70, nil)
+	set.AddOrUpdate("me", 75, "value")
+	set.Remove("low")
+	check(RankChange{"me", 2, 3, "me"}, RankChange{"me", 3, 2, "low"})
+
+	set.PopMax()
+	set.Remove("me")
+	set.AddOrUpdate("me", 0, nil)
+	check(RankChange{"me", 2, 0, "me"}, RankChange{"me", 0, 1, "me"})
+
+	sub.Close()
+	set.AddOrUpdate("first", -1, nil)
+	check()
+}
+
+/* Check that the rank kept by Watch follows FindRank through every kind of change. */
+func TestWatchFollowsFindRank(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	set := New(WithMaxSize(60, EvictMin), WithClock(func() time.Time { return now }))
+	keys := make([]string, 80)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key", i)
+	}
+	r := rand.New(rand.NewSource(1))
+	randomKey := func() string { return keys[r.Intn(len(keys))] }
+
+	ranks := make(map[string]int)
+	for _, key := range keys[:10] {
+		key := key
+		set.Watch(key, func(c RankChange) {
+			if c.OldRank != ranks[key] || c.Rank == c.OldRank {
+				t.Fatalf("change %v of %q, its rank was %d", c, key, ranks[key])
+			}
+			ranks[key] = c.Rank
+		})
+	}
+
+	for i := 0; i < 2000; i++ {
+		switch r.Intn(10) {
+		case 0:
+			set.Remove(randomKey())
+		case 1:
+			set.PopMin()
+		case 2:
+			set.GetByRankRange(r.Intn(5)+1, r.Intn(5)+5, true)
+		case 3:
+			entries := make([]Entry, r.Intn(20))
+			for j := range entries {
+				entries[j] = Entry{Key: randomKey(), Score: float64(r.Intn(100))}
+			}
+			unlimited := set.maxSize
+			set.maxSize = 0 // use the batch insertion instead of AddOrUpdate
+			set.AddOrUpdateBatch(entries)
+			set.maxSize = unlimited
+		case 4:
+			tx := set.Begin()
+			tx.AddOrUpdate(randomKey(), float64(r.Intn(100)), nil)
+			tx.Remove(randomKey())
+			tx.Rollback()
+		case 5:
+			set.AddOrUpdateWithTTL(randomKey(), float64(r.Intn(100)), nil, time.Duration(r.Intn(10))*time.Second)
+			now = now.Add(time.Second)
+		case 6:
+			if r.Intn(20) == 0 {
+				other := New()
+				for j := 0; j < 30; j++ {
+					other.AddOrUpdate(randomKey(), float64(r.Intn(100)), nil)
+				}
+				data, _ := other.MarshalBinary()
+				set.UnmarshalBinary(data)
+			}
+		case 7:
+			if r.Intn(20) == 0 {
+				set.GetByRankRange(1, -1, true)
+				entries := make([]Entry, 0, len(keys))
+				for j, key := range keys {
+					entries = append(entries, Entry{Key: key, Score: float64(j)})
+				}
+				set.BulkLoad(entries[:r.Intn(len(entries))])
+			}
+		default:
+			set.AddOrUpdate(randomKey(), float64(r.Intn(100)), nil)
+		}
+
+		for _, key := range keys[:10] {
+			if expected := set.FindRank(key); ranks[key] != expected {
+				t.Fatalf("step %d: watched rank of %q is %d, expected %d", i, key, ranks[key], expected)
+			}
+		}
+	}
+}